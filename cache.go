@@ -1,43 +1,170 @@
 package carta
 
 import (
+	"container/list"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 )
 
+// DefaultCacheSize is the number of mappers held by the cache before the least recently used one is evicted
+const DefaultCacheSize = 1024
+
 var mapperCache = newCache()
 
-type cache struct {
-	mapCache sync.Map
+// Cache holds the mappers generated by Map, one per column list and destination type, so that the
+// reflection work of matching columns to fields is done once rather than on every call.
+// When the cache is full the least recently used mapper is evicted.
+// The cache used by Map is returned by DefaultCache, it is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[cacheKey]*list.Element
+	lru     *list.List // front is the most recently used
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// CacheStats is a snapshot of the cache counters
+type CacheStats struct {
+	Len       int    // number of mappers in the cache
+	MaxSize   int    // maximum number of mappers, 0 if unbounded
+	Hits      uint64 // lookups which found a mapper
+	Misses    uint64 // lookups which had to generate a mapper
+	Evictions uint64 // mappers evicted to make room for new ones
+}
+
+type cacheItem struct {
+	key    cacheKey
+	mapper *Mapper
+}
+
+func newCache() *Cache {
+	return &Cache{
+		maxSize: DefaultCacheSize,
+		entries: map[cacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// DefaultCache returns the cache used by Map
+func DefaultCache() *Cache {
+	return mapperCache
+}
+
+// SetMaxSize sets the maximum number of mappers held by the cache, evicting the least recently used ones if needed.
+// A size of 0 or less makes the cache unbounded.
+func (c *Cache) SetMaxSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size < 0 {
+		size = 0
+	}
+	c.maxSize = size
+	c.evict()
+}
+
+// Len returns the number of mappers in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
 }
 
-func newCache() *cache {
-	return &cache{}
+// Reset removes every mapper from the cache and zeroes the counters, the maximum size is kept
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[cacheKey]*list.Element{}
+	c.lru.Init()
+	c.hits, c.misses, c.evictions = 0, 0, 0
 }
 
-type mapperEntry struct {
-	columns []string
+// Stats returns a snapshot of the cache counters
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Len:       c.lru.Len(),
+		MaxSize:   c.maxSize,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// cacheKey identifies a mapper by the destination type itself, rather than its name which is shared by
+// types of the same name in different packages, and by the names of the columns. Mappers do not depend
+// on the database types of the columns, so a mapper is shared by queries which only differ by types
+type cacheKey struct {
 	dst     reflect.Type
+	columns string
+	namer   FieldNamer // namer the mapper was built with, see WithFieldNamer
+}
+
+// newCacheKey encodes the columns with length prefixes, so that a column name containing
+// a delimiter cannot collide with a different list of columns
+func newCacheKey(columns []string, dst reflect.Type) cacheKey {
+	var b strings.Builder
+	for _, column := range columns {
+		b.WriteString(strconv.Itoa(len(column)))
+		b.WriteByte(':')
+		b.WriteString(column)
+	}
+	return cacheKey{dst: dst, columns: b.String()}
 }
 
-func (m *mapperEntry) raw() string {
-	// TODO: test how this works with unexported types
-	// TODO: add a way to provide fully qualified name for the type, since m.typ is always a pointer to a struct or slice
-	// return strings.Join(m.columns, ",") + "|" + m.dst.PkgPath() + "." + m.dst.String()
-	return strings.Join(m.columns, ",") + "|" + m.dst.String()
+func (c *Cache) loadMap(key cacheKey) (mapper *Mapper, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheItem).mapper, true
 }
 
-func (c *cache) loadMap(columns []string, dst reflect.Type) (mapper *Mapper, ok bool) {
-	entry := mapperEntry{columns, dst}
-	vmap, ok := c.mapCache.Load(entry.raw())
-	if ok {
-		mapper = vmap.(*Mapper)
+func (c *Cache) storeMap(key cacheKey, mapper *Mapper) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheItem).mapper = mapper
+		c.lru.MoveToFront(e)
+		return
 	}
-	return
+	c.entries[key] = c.lru.PushFront(&cacheItem{key: key, mapper: mapper})
+	c.evict()
 }
 
-func (c *cache) storeMap(columns []string, dst reflect.Type, mapper *Mapper) {
-	entry := mapperEntry{columns, dst}
-	c.mapCache.Store(entry.raw(), mapper)
+// evict removes the least recently used mappers until the cache fits its maximum size, c.mu must be held
+func (c *Cache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	for c.lru.Len() > c.maxSize {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*cacheItem).key)
+		c.evictions++
+	}
+}
+
+// Prewarm generates and caches the mapper of a result set with the given columns onto a T, where T is the
+// type which would be passed to Map as &dst, ie Prewarm[[]Blog](columns).
+// Calling it at startup moves the cost of generating mappers out of the first requests, and reports
+// mapping errors, such as ambiguous columns, before any query is run.
+func Prewarm[T any](columns []string) error {
+	dstTyp := reflect.TypeOf((*T)(nil))
+	mapper, _, err := buildMapper(dstTyp, columns, nil)
+	if err != nil {
+		return err
+	}
+	mapperCache.storeMap(newCacheKey(columns, dstTyp), mapper)
+	return nil
 }
@@ -1,8 +1,12 @@
 package carta
 
 import (
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestCache(t *testing.T) {
@@ -19,9 +23,9 @@ func TestCache(t *testing.T) {
 		t.Fatalf("error creating new mapper: %s", err)
 	}
 
-	mapperCache.storeMap(columns, dstTyp, m)
+	mapperCache.storeMap(newCacheKey(columns, dstTyp), m)
 
-	loadedMapper, ok := mapperCache.loadMap(columns, dstTyp)
+	loadedMapper, ok := mapperCache.loadMap(newCacheKey(columns, dstTyp))
 	if !ok {
 		t.Fatalf("expected to load mapper from cache, but it was not found")
 	}
@@ -30,3 +34,140 @@ func TestCache(t *testing.T) {
 		t.Errorf("loaded mapper is not the same as the stored mapper")
 	}
 }
+
+func TestCacheEviction(t *testing.T) {
+	c := newCache()
+	c.SetMaxSize(2)
+	dstTyp := reflect.TypeOf(&[]User{})
+	a, b, d := &Mapper{}, &Mapper{}, &Mapper{}
+
+	c.storeMap(newCacheKey([]string{"a"}, dstTyp), a)
+	c.storeMap(newCacheKey([]string{"b"}, dstTyp), b)
+	if _, ok := c.loadMap(newCacheKey([]string{"a"}, dstTyp)); !ok {
+		t.Fatalf("expected mapper a to be cached")
+	}
+	// b is now the least recently used mapper
+	c.storeMap(newCacheKey([]string{"d"}, dstTyp), d)
+
+	if _, ok := c.loadMap(newCacheKey([]string{"b"}, dstTyp)); ok {
+		t.Errorf("expected least recently used mapper b to be evicted")
+	}
+	for _, col := range []string{"a", "d"} {
+		if _, ok := c.loadMap(newCacheKey([]string{col}, dstTyp)); !ok {
+			t.Errorf("expected mapper %s to be cached", col)
+		}
+	}
+
+	expected := CacheStats{Len: 2, MaxSize: 2, Hits: 3, Misses: 1, Evictions: 1}
+	if stats := c.Stats(); stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+
+	c.SetMaxSize(1)
+	if c.Len() != 1 {
+		t.Errorf("expected shrinking the cache to evict down to 1 mapper, got %d", c.Len())
+	}
+
+	c.Reset()
+	expected = CacheStats{MaxSize: 1}
+	if stats := c.Stats(); stats != expected {
+		t.Errorf("expected stats %+v after reset, got %+v", expected, stats)
+	}
+}
+
+func TestCacheUnbounded(t *testing.T) {
+	c := newCache()
+	c.SetMaxSize(0)
+	dstTyp := reflect.TypeOf(&[]User{})
+	for i := 0; i < DefaultCacheSize+10; i++ {
+		c.storeMap(newCacheKey([]string{strconv.Itoa(i)}, dstTyp), &Mapper{})
+	}
+	if c.Len() != DefaultCacheSize+10 {
+		t.Errorf("expected unbounded cache to hold %d mappers, got %d", DefaultCacheSize+10, c.Len())
+	}
+}
+
+func TestPrewarm(t *testing.T) {
+	defer func() {
+		mapperCache = newCache()
+	}()
+	mapperCache = newCache()
+
+	columns := []string{"ID", "Name"}
+	if err := Prewarm[[]User](columns); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultCache().Len() != 1 {
+		t.Fatalf("expected 1 cached mapper, got %d", DefaultCache().Len())
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// the driver reports the database types of the columns, which Prewarm does not know
+	mockRows := sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("ID").OfType("INT4", 0),
+		sqlmock.NewColumn("Name").OfType("TEXT", ""),
+	).AddRow(1, "John Doe")
+	mock.ExpectQuery("SELECT").WillReturnRows(mockRows)
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []User
+	if err := Map(rows, &users); err != nil {
+		t.Fatal(err)
+	}
+	if stats := DefaultCache().Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("expected Map to use the prewarmed mapper, got %+v", stats)
+	}
+
+	if err := Prewarm[int]([]string{"ID"}); !errors.Is(err, ErrInvalidDestination) {
+		t.Errorf("expected ErrInvalidDestination for a destination which is not a struct, slice or array, got %v", err)
+	}
+}
+
+func TestCacheKeyCollisions(t *testing.T) {
+	pkgUser := reflect.TypeOf(&[]User{})
+	// shadows the package level User, both types are named carta.User
+	type User struct {
+		ID   int
+		Name string
+	}
+	localUser := reflect.TypeOf(&[]User{})
+	if pkgUser.String() != localUser.String() {
+		t.Fatalf("expected %v and %v to share a name", pkgUser, localUser)
+	}
+
+	testCases := []struct {
+		name   string
+		first  cacheKey
+		second cacheKey
+	}{
+		{
+			name:   "Column name containing a comma",
+			first:  newCacheKey([]string{"a,b"}, pkgUser),
+			second: newCacheKey([]string{"a", "b"}, pkgUser),
+		},
+		{
+			name:   "Column name containing a length prefix",
+			first:  newCacheKey([]string{"a0:"}, pkgUser),
+			second: newCacheKey([]string{"a", ""}, pkgUser),
+		},
+		{
+			name:   "Types with the same name",
+			first:  newCacheKey([]string{"ID", "Name"}, pkgUser),
+			second: newCacheKey([]string{"ID", "Name"}, localUser),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.first == tc.second {
+				t.Errorf("expected keys %+v and %+v to differ", tc.first, tc.second)
+			}
+		})
+	}
+}
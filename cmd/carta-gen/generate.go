@@ -0,0 +1,746 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hackafterdark/carta"
+	"github.com/hackafterdark/carta/value"
+)
+
+// field is an exported field of a struct declared in the package
+type field struct {
+	name string
+	expr string       // Go source of the field type, ie []*Post
+	typ  reflect.Type // synthetic type of the field, used to compute the mapping with carta.Explain
+	st   *structType  // struct of struct, *struct, []struct or []*struct fields
+}
+
+// structType is a struct declared in the package, typ is built with reflect.StructOf
+// so that carta.Explain applies the same rules to it as carta.Map would to the real type
+type structType struct {
+	name   string
+	fields []*field
+	typ    reflect.Type
+}
+
+func (s *structType) field(name string) *field {
+	for _, f := range s.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// pkg holds the type declarations of the package being generated for
+type pkg struct {
+	name    string
+	specs   map[string]*ast.TypeSpec
+	imports map[*ast.TypeSpec]map[string]string // import name to path, for the file declaring each type
+	methods map[string]map[string]bool          // type name to method names
+	structs map[string]*structType
+	loading map[string]bool
+}
+
+var knownTypes = map[string]reflect.Type{
+	"time.Time":                reflect.TypeOf(time.Time{}),
+	"database/sql.NullBool":    reflect.TypeOf(sql.NullBool{}),
+	"database/sql.NullFloat64": reflect.TypeOf(sql.NullFloat64{}),
+	"database/sql.NullInt32":   reflect.TypeOf(sql.NullInt32{}),
+	"database/sql.NullInt64":   reflect.TypeOf(sql.NullInt64{}),
+	"database/sql.NullString":  reflect.TypeOf(sql.NullString{}),
+	"database/sql.NullTime":    reflect.TypeOf(sql.NullTime{}),
+	"math/big.Int":             reflect.TypeOf(big.Int{}),
+	"math/big.Rat":             reflect.TypeOf(big.Rat{}),
+	"math/big.Float":           reflect.TypeOf(big.Float{}),
+}
+
+var builtinTypes = map[string]reflect.Type{
+	"bool":    reflect.TypeOf(false),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"string":  reflect.TypeOf(""),
+	"byte":    reflect.TypeOf(byte(0)),
+	"rune":    reflect.TypeOf(rune(0)),
+}
+
+func loadPackage(dir string) (*pkg, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	p := &pkg{
+		specs:   map[string]*ast.TypeSpec{},
+		imports: map[*ast.TypeSpec]map[string]string{},
+		methods: map[string]map[string]bool{},
+		structs: map[string]*structType{},
+		loading: map[string]bool{},
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		p.name = file.Name.Name
+		imports := map[string]string{}
+		for _, imp := range file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports[name] = path
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						p.specs[spec.Name.Name] = spec
+						p.imports[spec] = imports
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil || len(decl.Recv.List) == 0 {
+					continue
+				}
+				recv := decl.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if ident, ok := recv.(*ast.Ident); ok {
+					if p.methods[ident.Name] == nil {
+						p.methods[ident.Name] = map[string]bool{}
+					}
+					p.methods[ident.Name][decl.Name.Name] = true
+				}
+			}
+		}
+	}
+	if p.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// structOf builds the struct declared as name
+func (p *pkg) structOf(name string) (*structType, error) {
+	if s, ok := p.structs[name]; ok {
+		return s, nil
+	}
+	if p.loading[name] {
+		return nil, fmt.Errorf("recursive type %s is not supported", name)
+	}
+	spec, ok := p.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("type %s is not declared in package %s", name, p.name)
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", name)
+	}
+	p.loading[name] = true
+	defer delete(p.loading, name)
+
+	s := &structType{name: name}
+	var structFields []reflect.StructField
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			if hasConverter(tag) {
+				return nil, fmt.Errorf("%s.%s: converters selected with the %s db tag option are not supported", name, ident.Name, carta.ConvOption)
+			}
+			typ, expr, fieldStruct, err := p.resolve(spec, f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, ident.Name, err)
+			}
+			s.fields = append(s.fields, &field{name: ident.Name, expr: expr, typ: typ, st: fieldStruct})
+			structFields = append(structFields, reflect.StructField{Name: ident.Name, Type: typ, Tag: reflect.StructTag(tag)})
+		}
+	}
+	s.typ = reflect.StructOf(structFields)
+	p.structs[name] = s
+	return s, nil
+}
+
+// hasConverter reports whether the db tag of a field selects a converter, ie `db:"price,conv=cents"`
+func hasConverter(tag string) bool {
+	for _, option := range strings.Split(reflect.StructTag(tag).Get(carta.DbTagKey), ",")[1:] {
+		if key, _, _ := strings.Cut(strings.TrimSpace(option), "="); key == carta.ConvOption {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the synthetic type of a type expression, its Go source, and the struct it refers to, if any
+func (p *pkg) resolve(spec *ast.TypeSpec, expr ast.Expr) (reflect.Type, string, *structType, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if t, ok := builtinTypes[expr.Name]; ok {
+			return t, expr.Name, nil, nil
+		}
+		decl, ok := p.specs[expr.Name]
+		if !ok {
+			return nil, "", nil, fmt.Errorf("type %s is not declared in package %s", expr.Name, p.name)
+		}
+		if p.methods[expr.Name]["Scan"] || p.methods[expr.Name]["UnmarshalText"] {
+			return nil, "", nil, fmt.Errorf("type %s implements sql.Scanner or encoding.TextUnmarshaler, which is not supported", expr.Name)
+		}
+		if _, ok := decl.Type.(*ast.StructType); ok {
+			s, err := p.structOf(expr.Name)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			return s.typ, expr.Name, s, nil
+		}
+		// named basic types are mapped as their underlying type
+		t, _, s, err := p.resolve(decl, decl.Type)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if s != nil || !(isBasicKind(t.Kind()) || value.IsUUIDType(t)) {
+			return nil, "", nil, fmt.Errorf("type %s is not supported", expr.Name)
+		}
+		return t, expr.Name, nil, nil
+	case *ast.StarExpr:
+		t, src, s, err := p.resolve(spec, expr.X)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return reflect.PtrTo(t), "*" + src, s, nil
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			// [16]byte is a UUID, other arrays are not supported
+			if lit, ok := expr.Len.(*ast.BasicLit); ok && lit.Value == "16" {
+				if t, _, _, err := p.resolve(spec, expr.Elt); err == nil && t.Kind() == reflect.Uint8 {
+					return reflect.ArrayOf(16, t), "[16]byte", nil, nil
+				}
+			}
+			return nil, "", nil, fmt.Errorf("arrays are not supported")
+		}
+		t, src, s, err := p.resolve(spec, expr.Elt)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return reflect.SliceOf(t), "[]" + src, s, nil
+	case *ast.SelectorExpr:
+		if pkgIdent, ok := expr.X.(*ast.Ident); ok {
+			path := p.imports[spec][pkgIdent.Name]
+			if t, ok := knownTypes[path+"."+expr.Sel.Name]; ok {
+				return t, t.String(), nil, nil
+			}
+			return nil, "", nil, fmt.Errorf("type %s.%s is not supported", pkgIdent.Name, expr.Sel.Name)
+		}
+	}
+	return nil, "", nil, fmt.Errorf("type %s is not supported", types.ExprString(expr))
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// generate returns the source of a file declaring funcName, which maps rows with the given columns onto a *[]typeName
+func generate(dir string, typeName string, funcName string, columns []string) ([]byte, error) {
+	p, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.structOf(typeName)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := carta.Explain(columns, reflect.New(reflect.SliceOf(root.typ)).Interface())
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.UnclaimedColumns) > 0 {
+		return nil, fmt.Errorf("columns %s are not mapped onto any field of %s", strings.Join(plan.UnclaimedColumns, ", "), typeName)
+	}
+
+	g := &generator{
+		pkgName:  p.name,
+		funcName: funcName,
+		columns:  map[string]int{},
+		hooks:    map[string]bool{},
+	}
+	for name, methods := range p.methods {
+		g.hooks[name] = methods["AfterCartaMap"]
+	}
+	for i, c := range columns {
+		if _, ok := g.columns[c]; ok {
+			return nil, fmt.Errorf("column %s is selected more than once", c)
+		}
+		g.columns[c] = i
+	}
+	if err := g.root(plan.Root, root); err != nil {
+		return nil, err
+	}
+	return g.file(p.name, typeName, columns)
+}
+
+type generator struct {
+	pkgName  string
+	funcName string
+	columns  map[string]int
+	body     bytes.Buffer // body of the row loop
+	maps     []string     // declarations of the maps which index elements by their identity
+	usesTime bool
+	usesBig  bool            // the generated code names math/big types, ie in slices of big numbers
+	n        int             // number of nodes generated, used to name variables
+	hooks    map[string]bool // structs which implement carta.AfterMapper
+	depth    int             // number of levels of elements which implement carta.AfterMapper
+}
+
+// level is an element whose fields and children are being generated
+type level struct {
+	elem     string   // expression of a pointer to the element, ie e1
+	key      string   // variable holding the identity of the element and its ancestors, ie k1
+	path     string   // format of the Go path of the element, ie Blog.Posts[%d]
+	pathArgs []string // arguments of the path format
+	addr     string   // expression of the element which stays valid after every row is loaded, ie out[i0].Posts[i2]
+	depth    int      // depth of the element, 0 for elements of dst
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) typeExpr(expr string) string {
+	if strings.Contains(expr, "time.Time") {
+		g.usesTime = true
+	}
+	if strings.Contains(expr, "big.") {
+		g.usesBig = true
+	}
+	return expr
+}
+
+// pathExpr is the Go expression of a path, a string literal or a fmt.Sprintf call
+func pathExpr(path string, args []string) string {
+	if len(args) == 0 {
+		return strconv.Quote(path)
+	}
+	return fmt.Sprintf("fmt.Sprintf(%s, %s)", strconv.Quote(path), strings.Join(args, ", "))
+}
+
+func (g *generator) root(node *carta.PlanNode, s *structType) error {
+	g.maps = append(g.maps, "idx0 := map[string]int{}")
+	g.printf("k0 := g.identity(nil, %s)\n", g.identityArgs(node))
+	g.printf("i0, ok0 := idx0[string(k0)]\n")
+	g.printf("if !ok0 {\n")
+	g.printf("var e %s\n", s.name)
+	if err := g.fields(node, s, "e", s.name, nil); err != nil {
+		return err
+	}
+	g.printf("out = append(out, e)\n")
+	g.printf("i0 = len(out) - 1\n")
+	g.printf("idx0[string(k0)] = i0\n")
+	g.afterMap(s, "out[i0]", 0)
+	g.printf("}\n")
+	if !hasChildren(node) {
+		return nil
+	}
+	g.printf("e0 := &out[i0]\n")
+	return g.children(node, s, level{elem: "e0", key: "k0", path: s.name, addr: "out[i0]"})
+}
+
+// afterMap queues the call of AfterCartaMap on a new element, if s implements carta.AfterMapper.
+// The calls of each depth are run after every row is loaded, deepest first, so that as with carta.Map
+// the children of an element are complete when it is called
+func (g *generator) afterMap(s *structType, addr string, depth int) {
+	if !g.hooks[s.name] {
+		return
+	}
+	if depth >= g.depth {
+		g.depth = depth + 1
+	}
+	g.printf("after[%d] = append(after[%d], func() error {\n", depth, depth)
+	g.printf("return %sAfterMapError(%q, %s.AfterCartaMap())\n", lowerFirst(g.funcName), g.pkgName+"."+s.name, addr)
+	g.printf("})\n")
+}
+
+// identityArgs are the cells which identify the elements of node
+func (g *generator) identityArgs(node *carta.PlanNode) string {
+	args := make([]string, len(node.IdentityColumns))
+	for i, c := range node.IdentityColumns {
+		args[i] = fmt.Sprintf("&cells[%d]", g.columns[c])
+	}
+	return strings.Join(args, ", ")
+}
+
+// fields sets the basic fields of a new element, and initializes its collections to empty slices as carta.Map does
+func (g *generator) fields(node *carta.PlanNode, s *structType, target string, path string, pathArgs []string) error {
+	for _, pf := range node.Fields {
+		if pf.Column == "" {
+			continue
+		}
+		f := s.field(pf.Name)
+		if f == nil {
+			return fmt.Errorf("%s: numbered column groups are not supported", pf.Name)
+		}
+		g.set(g.columns[pf.Column], pf.Column, f.typ, f.expr, path+"."+f.name, pathArgs, false, func(v string) string {
+			return fmt.Sprintf("%s.%s = %s\n", target, f.name, v)
+		})
+	}
+	for _, child := range node.Children {
+		f := s.field(childName(child))
+		if child.Cardinality != "collection" {
+			continue
+		}
+		if strings.HasPrefix(f.expr, "*") {
+			g.printf("%s.%s = &%s{}\n", target, f.name, g.typeExpr(f.expr[1:]))
+		} else {
+			g.printf("%s.%s = %s{}\n", target, f.name, g.typeExpr(f.expr))
+		}
+	}
+	return nil
+}
+
+// hasChildren reports whether rows load any relationship of node
+func hasChildren(node *carta.PlanNode) bool {
+	for _, child := range node.Children {
+		if len(child.IdentityColumns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func childName(node *carta.PlanNode) string {
+	return node.Path[strings.LastIndex(node.Path, ".")+1:]
+}
+
+// set loads the cell of column col into a basic type, assign returns the statement which stores the loaded value.
+// NULL leaves pointers and sql.NullXXX types unset, skipNull skips NULL cells whatever the type
+func (g *generator) set(col int, column string, typ reflect.Type, expr string, path string, pathArgs []string, skipNull bool, assign func(v string) string) {
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+		expr = expr[1:]
+	}
+	var getter, natural string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		getter, natural = "Time", "time.Time"
+	case strings.HasPrefix(typ.String(), "sql.Null"):
+		getter, natural = typ.Name(), typ.String()
+	case strings.HasPrefix(typ.String(), "big."):
+		getter, natural = "Big"+typ.Name(), "*"+typ.String()
+	case value.IsUUIDType(typ):
+		getter, natural = "UUID", "[16]byte"
+	case typ.Kind() == reflect.Bool:
+		getter, natural = "Bool", "bool"
+	case typ.Kind() == reflect.String:
+		getter, natural = "String", "string"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		getter, natural = "Float64", "float64"
+	case typ.Kind() >= reflect.Uint && typ.Kind() <= reflect.Uint64:
+		getter, natural = "Uint64", "uint64"
+	default:
+		getter, natural = "Int64", "int64"
+	}
+	errPath := pathExpr(path, pathArgs)
+	v := fmt.Sprintf("v%d", col)
+	typeName := expr // type named in error messages, as reflect.Type.String would
+	if expr == "[16]byte" {
+		typeName = typ.String()
+	} else if _, builtin := builtinTypes[expr]; !builtin && !strings.Contains(expr, ".") {
+		typeName = g.pkgName + "." + expr
+	}
+
+	scoped := skipNull || isPtr || strings.HasPrefix(getter, "Null")
+	if scoped {
+		g.printf("if !cells[%d].IsNull() {\n", col)
+	} else {
+		g.printf("if cells[%d].IsNull() {\n", col)
+		g.printf("return %sError(carta.KindNullToNonNullable, row, %q, %s, fmt.Errorf(\"cannot load NULL into %s\"))\n", lowerFirst(g.funcName), column, errPath, typeName)
+		g.printf("}\n")
+	}
+	g.printf("%s, err := cells[%d].%s()\n", v, col, getter)
+	g.printf("if err != nil {\n")
+	g.printf("return %sError(carta.KindConversion, row, %q, %s, fmt.Errorf(\"cannot convert to %s: %%w\", err))\n", lowerFirst(g.funcName), column, errPath, typeName)
+	g.printf("}\n")
+	if strings.HasPrefix(getter, "Big") {
+		if !isPtr {
+			v = "*" + v // the getter returns a new *big.XXX, pointer fields keep it
+		}
+	} else {
+		if expr != natural {
+			v = fmt.Sprintf("%s(%s)", expr, v)
+		}
+		if isPtr {
+			g.printf("x%d := %s\n", col, v)
+			v = fmt.Sprintf("&x%d", col)
+		}
+	}
+	g.printf("%s", assign(v))
+	if scoped {
+		g.printf("}\n")
+	}
+}
+
+// children loads the has-one and has-many relationships of the element at lv
+func (g *generator) children(node *carta.PlanNode, s *structType, lv level) error {
+	for _, child := range node.Children {
+		f := s.field(childName(child))
+		if len(child.IdentityColumns) == 0 {
+			continue // no columns are mapped onto the child, carta.Map never loads it
+		}
+		target := lv.elem + "." + f.name
+		addr := lv.addr + "." + f.name
+		listExpr := f.expr
+		if strings.HasPrefix(listExpr, "*[]") {
+			target = "(*" + target + ")"
+			addr = "(*" + addr + ")"
+			listExpr = listExpr[1:]
+		}
+		if child.Basic {
+			g.basicCollection(child, f, target, listExpr, lv)
+			continue
+		}
+
+		g.n++
+		n := g.n
+		// carta.Map skips children whose columns are all NULL
+		nulls := make([]string, len(child.IdentityColumns))
+		for i, c := range child.IdentityColumns {
+			nulls[i] = fmt.Sprintf("cells[%d].IsNull()", g.columns[c])
+		}
+		if len(nulls) > 1 {
+			g.printf("if !(%s) {\n", strings.Join(nulls, " && "))
+		} else {
+			g.printf("if !%s {\n", nulls[0])
+		}
+		var err error
+		if child.Cardinality == "collection" {
+			err = g.collection(n, child, f, target, addr, listExpr, lv)
+		} else {
+			err = g.association(n, child, f, addr, lv)
+		}
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+	}
+	return nil
+}
+
+// basicCollection appends the column of every row to a slice of a basic type, basic collections are not de-duplicated
+func (g *generator) basicCollection(node *carta.PlanNode, f *field, target string, listExpr string, lv level) {
+	pf := node.Fields[0]
+	elemExpr := listExpr[2:]
+	path := lv.path + "." + f.name + "[%d]"
+	args := append(lv.pathArgs[:len(lv.pathArgs):len(lv.pathArgs)], "len("+target+")")
+	listTyp := f.typ
+	if listTyp.Kind() == reflect.Ptr {
+		listTyp = listTyp.Elem()
+	}
+	// carta.Map skips NULL elements of basic collections
+	g.set(g.columns[pf.Column], pf.Column, listTyp.Elem(), elemExpr, path, args, true, func(v string) string {
+		return fmt.Sprintf("%s = append(%s, %s)\n", target, target, v)
+	})
+}
+
+func (g *generator) collection(n int, node *carta.PlanNode, f *field, target string, listAddr string, listExpr string, lv level) error {
+	elemExpr := listExpr[2:]
+	isPtr := strings.HasPrefix(elemExpr, "*")
+	idx, key, i, ok, e := fmt.Sprintf("idx%d", n), fmt.Sprintf("k%d", n), fmt.Sprintf("i%d", n), fmt.Sprintf("ok%d", n), fmt.Sprintf("e%d", n)
+	g.maps = append(g.maps, idx+" := map[string]int{}")
+
+	g.printf("%s := g.identity(%s, %s)\n", key, lv.key, g.identityArgs(node))
+	g.printf("%s, %s := %s[string(%s)]\n", i, ok, idx, key)
+	g.printf("if !%s {\n", ok)
+	g.printf("var e %s\n", f.st.name)
+	path := lv.path + "." + f.name + "[%d]"
+	args := append(lv.pathArgs[:len(lv.pathArgs):len(lv.pathArgs)], "len("+target+")")
+	if err := g.fields(node, f.st, "e", path, args); err != nil {
+		return err
+	}
+	if isPtr {
+		g.printf("%s = append(%s, &e)\n", target, target)
+	} else {
+		g.printf("%s = append(%s, e)\n", target, target)
+	}
+	g.printf("%s = len(%s) - 1\n", i, target)
+	g.printf("%s[string(%s)] = %s\n", idx, key, i)
+	addr := listAddr + "[" + i + "]"
+	g.afterMap(f.st, addr, lv.depth+1)
+	g.printf("}\n")
+	if !hasChildren(node) {
+		return nil
+	}
+	if isPtr {
+		g.printf("%s := %s[%s]\n", e, target, i)
+	} else {
+		g.printf("%s := &%s[%s]\n", e, target, i)
+	}
+	return g.children(node, f.st, level{
+		elem:     e,
+		key:      key,
+		path:     path,
+		pathArgs: append(lv.pathArgs[:len(lv.pathArgs):len(lv.pathArgs)], i),
+		addr:     addr,
+		depth:    lv.depth + 1,
+	})
+}
+
+// association sets a has-one relationship. As carta.Map does, rows which hold more than one distinct association
+// for the same element are an error matching carta.ErrCardinality
+func (g *generator) association(n int, node *carta.PlanNode, f *field, addr string, lv level) error {
+	isPtr := strings.HasPrefix(f.expr, "*")
+	assoc, key, e := fmt.Sprintf("assoc%d", n), fmt.Sprintf("k%d", n), fmt.Sprintf("e%d", n)
+	g.maps = append(g.maps, assoc+" := map[string]string{}")
+
+	g.printf("%s := g.identity(%s, %s)\n", key, lv.key, g.identityArgs(node))
+	g.printf("if cur, ok := %s[string(%s)]; !ok {\n", assoc, lv.key)
+	g.printf("%s[string(%s)] = string(%s)\n", assoc, lv.key, key)
+	g.printf("var e %s\n", f.st.name)
+	path := lv.path + "." + f.name
+	if err := g.fields(node, f.st, "e", path, lv.pathArgs); err != nil {
+		return err
+	}
+	if isPtr {
+		g.printf("%s.%s = &e\n", lv.elem, f.name)
+	} else {
+		g.printf("%s.%s = e\n", lv.elem, f.name)
+	}
+	g.afterMap(f.st, addr, lv.depth+1)
+	g.printf("} else if cur != string(%s) {\n", key)
+	g.printf("return %sError(carta.KindCardinality, row, \"\", %s, fmt.Errorf(\"rows hold more than one distinct %s for a has-one relationship\"))\n",
+		lowerFirst(g.funcName), pathExpr(path, lv.pathArgs), g.pkgName+"."+f.st.name)
+	g.printf("}\n")
+	if !hasChildren(node) {
+		return nil
+	}
+	if isPtr {
+		g.printf("%s := %s.%s\n", e, lv.elem, f.name)
+	} else {
+		g.printf("%s := &%s.%s\n", e, lv.elem, f.name)
+	}
+	return g.children(node, f.st, level{elem: e, key: key, path: path, pathArgs: lv.pathArgs, addr: addr, depth: lv.depth + 1})
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func (g *generator) file(pkgName string, typeName string, columns []string) ([]byte, error) {
+	var b bytes.Buffer
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = strconv.Quote(c)
+	}
+	lower := lowerFirst(g.funcName)
+
+	fmt.Fprintf(&b, "// Code generated by carta-gen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "import (\n\"database/sql\"\n\"fmt\"\n")
+	if g.usesBig {
+		fmt.Fprintf(&b, "\"math/big\"\n")
+	}
+	if g.usesTime {
+		fmt.Fprintf(&b, "\"time\"\n")
+	}
+	fmt.Fprintf(&b, "\n\"github.com/hackafterdark/carta\"\n\"github.com/hackafterdark/carta/value\"\n)\n\n")
+
+	fmt.Fprintf(&b, "var %sColumns = []string{%s}\n\n", lower, strings.Join(quoted, ", "))
+
+	fmt.Fprintf(&b, "// %s maps rows onto dst, as carta.Map(rows, dst) would, without reflection.\n", g.funcName)
+	fmt.Fprintf(&b, "// The columns of rows must be, in order: %s\n", strings.Join(columns, ", "))
+	fmt.Fprintf(&b, "func %s(rows *sql.Rows, dst *[]%s) error {\n", g.funcName, typeName)
+	fmt.Fprintf(&b, "defer rows.Close()\n")
+	fmt.Fprintf(&b, "columns, err := rows.Columns()\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(&b, "if len(columns) != len(%sColumns) {\n", lower)
+	fmt.Fprintf(&b, "return %sError(carta.KindColumnMismatch, 0, \"\", \"\", fmt.Errorf(\"%s expects %%d columns, got %%d\", len(%sColumns), len(columns)))\n}\n", lower, g.funcName, lower)
+	fmt.Fprintf(&b, "for i, column := range columns {\nif column != %sColumns[i] {\n", lower)
+	fmt.Fprintf(&b, "return %sError(carta.KindColumnMismatch, 0, column, \"\", fmt.Errorf(\"%s expects column %%d to be %%s\", i+1, %sColumns[i]))\n}\n}\n\n", lower, g.funcName, lower)
+
+	fmt.Fprintf(&b, "cells := make([]value.Cell, len(columns))\nargs := make([]interface{}, len(columns))\nfor i := range cells {\nargs[i] = &cells[i]\n}\n")
+	fmt.Fprintf(&b, "g := &%sIdentity{}\n", lower)
+	for _, m := range g.maps {
+		fmt.Fprintf(&b, "%s\n", m)
+	}
+	if g.depth > 0 {
+		fmt.Fprintf(&b, "after := make([][]func() error, %d)\n", g.depth)
+	}
+	fmt.Fprintf(&b, "out := *dst\nrow := 0\n")
+	fmt.Fprintf(&b, "for rows.Next() {\nif err := rows.Scan(args...); err != nil {\nreturn err\n}\nrow++\ng.reset()\n")
+	b.Write(g.body.Bytes())
+	fmt.Fprintf(&b, "}\nif err := rows.Err(); err != nil {\nreturn err\n}\n")
+	if g.depth > 0 {
+		fmt.Fprintf(&b, "for d := len(after) - 1; d >= 0; d-- {\nfor _, f := range after[d] {\nif err := f(); err != nil {\nreturn err\n}\n}\n}\n")
+	}
+	fmt.Fprintf(&b, "*dst = out\nreturn nil\n}\n\n")
+
+	fmt.Fprintf(&b, "func %sError(kind carta.ErrorKind, row int, column string, path string, err error) error {\n", lower)
+	fmt.Fprintf(&b, "return &carta.MappingError{Kind: kind, Column: column, FieldPath: path, Row: row, Err: err}\n}\n\n")
+	if g.depth > 0 {
+		fmt.Fprintf(&b, "func %sAfterMapError(typ string, err error) error {\nif err != nil {\n", lower)
+		fmt.Fprintf(&b, "return fmt.Errorf(\"carta: %%s.AfterCartaMap: %%w\", typ, err)\n}\nreturn nil\n}\n\n")
+	}
+
+	fmt.Fprintf(&b, "// %sIdentity builds the identities of the elements of a row in a single buffer.\n", lower)
+	fmt.Fprintf(&b, "// The identity of an element is prefixed with the identity of its parent, cell ids are self-delimiting\n")
+	fmt.Fprintf(&b, "// so that the identities of different elements cannot be equal\n")
+	fmt.Fprintf(&b, "type %sIdentity struct {\nbuf []byte\n}\n\n", lower)
+	fmt.Fprintf(&b, "func (g *%sIdentity) reset() {\ng.buf = g.buf[:0]\n}\n\n", lower)
+	fmt.Fprintf(&b, "func (g *%sIdentity) identity(parent []byte, cells ...*value.Cell) []byte {\n", lower)
+	fmt.Fprintf(&b, "start := len(g.buf)\ng.buf = append(g.buf, parent...)\nfor _, c := range cells {\ng.buf = c.AppendUid(g.buf)\n}\nreturn g.buf[start:]\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w\n%s", err, b.Bytes())
+	}
+	return src, nil
+}
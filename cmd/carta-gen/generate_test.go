@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateExample checks that the committed example is up to date with the generator
+func TestGenerateExample(t *testing.T) {
+	columns := "id,title,created_at,status,tags,author->id,author->username,author->email,posts->id,posts->title,posts->score,posts->comments->id,posts->comments->body"
+	src, err := generate("internal/example", "Blog", "MapBlog", strings.Split(columns, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("internal/example/blog_carta.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, committed) {
+		t.Error("internal/example/blog_carta.go is out of date, run go generate ./cmd/carta-gen/...")
+	}
+}
+
+// generateAndVet generates the mapping function of typeName, declared by src, into a package under testdata and runs
+// go vet on it, so that the generated code is known to compile
+func generateAndVet(t *testing.T, src string, typeName string, columns []string) []byte {
+	t.Helper()
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Remove("testdata") // only removed if no other test uses it
+	})
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := generate(dir, typeName, "Map"+typeName, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "types_carta.go"), out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if vet, err := exec.Command("go", "vet", "./"+filepath.ToSlash(dir)).CombinedOutput(); err != nil {
+		t.Fatalf("go vet of the generated code failed: %v\n%s\n%s", err, vet, out)
+	}
+	return out
+}
+
+// TestGenerateBigNumbers checks that math/big fields are loaded with the getters of value.Cell which parse the text of
+// NUMERIC columns
+func TestGenerateBigNumbers(t *testing.T) {
+	src := "package accounts\n\nimport \"math/big\"\n\ntype Account struct {\n\tId big.Int `db:\"id\"`\n\tBalance *big.Rat `db:\"balance\"`\n\tAmounts []big.Rat `db:\"amounts\"`\n\tRates []*big.Float `db:\"rates\"`\n}\n"
+	out := generateAndVet(t, src, "Account", []string{"id", "balance", "amounts", "rates"})
+	for _, expected := range []string{"v0, err := cells[0].BigInt()", "e.Id = *v0", "v1, err := cells[1].BigRat()", "e.Balance = v1", "\"math/big\""} {
+		if !bytes.Contains(out, []byte(expected)) {
+			t.Errorf("expected the generated code to contain %q:\n%s", expected, out)
+		}
+	}
+}
+
+// TestGenerateUUIDs checks that types with a [16]byte underlying type are loaded with the UUID getter of value.Cell
+func TestGenerateUUIDs(t *testing.T) {
+	src := "package accounts\n\ntype ID [16]byte\n\ntype Account struct {\n\tId ID `db:\"id\"`\n\tRef *[16]byte `db:\"ref\"`\n\tOwners []ID `db:\"owners\"`\n}\n"
+	out := generateAndVet(t, src, "Account", []string{"id", "ref", "owners"})
+	for _, expected := range []string{"v0, err := cells[0].UUID()", "e.Id = ID(v0)", "v1, err := cells[1].UUID()", "cannot convert to [16]uint8"} {
+		if !bytes.Contains(out, []byte(expected)) {
+			t.Errorf("expected the generated code to contain %q:\n%s", expected, out)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		columns []string
+		err     string
+	}{
+		{
+			name:    "unclaimed column",
+			src:     "type User struct {\n\tId int `db:\"id\"`\n}",
+			columns: []string{"id", "name"},
+			err:     "columns name are not mapped",
+		},
+		{
+			name:    "duplicate column",
+			src:     "type User struct {\n\tId int `db:\"id\"`\n}",
+			columns: []string{"id", "id"},
+			err:     "selected more than once",
+		},
+		{
+			name:    "unsupported type",
+			src:     "type User struct {\n\tId int `db:\"id\"`\n\tTags map[string]string\n}",
+			columns: []string{"id"},
+			err:     "User.Tags: type map[string]string is not supported",
+		},
+		{
+			name:    "array",
+			src:     "type User struct {\n\tId int `db:\"id\"`\n\tCode [8]byte `db:\"code\"`\n}",
+			columns: []string{"id", "code"},
+			err:     "arrays are not supported",
+		},
+		{
+			name:    "scanner",
+			src:     "type Money int64\n\nfunc (m *Money) Scan(src interface{}) error { return nil }\n\ntype User struct {\n\tBalance Money `db:\"balance\"`\n}",
+			columns: []string{"balance"},
+			err:     "implements sql.Scanner",
+		},
+		{
+			name:    "converter",
+			src:     "type User struct {\n\tBalance int64 `db:\"balance,conv=cents\"`\n}",
+			columns: []string{"balance"},
+			err:     "User.Balance: converters selected with the conv db tag option are not supported",
+		},
+		{
+			name:    "recursive type",
+			src:     "type User struct {\n\tId int `db:\"id\"`\n\tFriends []User `carta:\"friends\"`\n}",
+			columns: []string{"id"},
+			err:     "recursive type User",
+		},
+		{
+			name:    "missing type",
+			src:     "type Post struct{}",
+			columns: []string{"id"},
+			err:     "type User is not declared",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "user.go"), []byte("package users\n\n"+tt.src+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := generate(dir, "User", "MapUser", tt.columns)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package example holds the types of the carta-gen example, MapBlog in blog_carta.go is generated from them
+package example
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+//go:generate go run github.com/hackafterdark/carta/cmd/carta-gen -type Blog -columns "id,title,created_at,status,tags,author->id,author->username,author->email,posts->id,posts->title,posts->score,posts->comments->id,posts->comments->body"
+
+type Status string
+
+type Blog struct {
+	Id        int       `db:"id"`
+	Title     string    `db:"title"`
+	CreatedAt time.Time `db:"created_at"`
+	Status    Status    `db:"status"`
+	Tags      []string  `db:"tags"`
+	Author    *Author   `carta:"author"`
+	Posts     []Post    `carta:"posts"`
+
+	CommentCount int // set by AfterCartaMap
+}
+
+// AfterCartaMap counts the comments of the posts of the blog, which are counted first
+func (b *Blog) AfterCartaMap() error {
+	b.CommentCount = 0
+	for _, p := range b.Posts {
+		b.CommentCount += p.CommentCount
+	}
+	return nil
+}
+
+type Author struct {
+	Id       int            `db:"id"`
+	Username string         `db:"username"`
+	Email    sql.NullString `db:"email"`
+}
+
+type Post struct {
+	Id       int        `db:"id"`
+	Title    string     `db:"title"`
+	Score    *float64   `db:"score"`
+	Comments []*Comment `carta:"comments"`
+
+	CommentCount int // set by AfterCartaMap
+}
+
+func (p *Post) AfterCartaMap() error {
+	p.CommentCount = len(p.Comments)
+	return nil
+}
+
+type Comment struct {
+	Id   int    `db:"id"`
+	Body string `db:"body"`
+}
+
+// ErrEmptyComment is returned by AfterCartaMap for comments without a body
+var ErrEmptyComment = errors.New("empty comment")
+
+func (c *Comment) AfterCartaMap() error {
+	if c.Body == "" {
+		return ErrEmptyComment
+	}
+	return nil
+}
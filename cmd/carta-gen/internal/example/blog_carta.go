@@ -0,0 +1,249 @@
+// Code generated by carta-gen; DO NOT EDIT.
+
+package example
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/hackafterdark/carta"
+	"github.com/hackafterdark/carta/value"
+)
+
+var mapBlogColumns = []string{"id", "title", "created_at", "status", "tags", "author->id", "author->username", "author->email", "posts->id", "posts->title", "posts->score", "posts->comments->id", "posts->comments->body"}
+
+// MapBlog maps rows onto dst, as carta.Map(rows, dst) would, without reflection.
+// The columns of rows must be, in order: id, title, created_at, status, tags, author->id, author->username, author->email, posts->id, posts->title, posts->score, posts->comments->id, posts->comments->body
+func MapBlog(rows *sql.Rows, dst *[]Blog) error {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if len(columns) != len(mapBlogColumns) {
+		return mapBlogError(carta.KindColumnMismatch, 0, "", "", fmt.Errorf("MapBlog expects %d columns, got %d", len(mapBlogColumns), len(columns)))
+	}
+	for i, column := range columns {
+		if column != mapBlogColumns[i] {
+			return mapBlogError(carta.KindColumnMismatch, 0, column, "", fmt.Errorf("MapBlog expects column %d to be %s", i+1, mapBlogColumns[i]))
+		}
+	}
+
+	cells := make([]value.Cell, len(columns))
+	args := make([]interface{}, len(columns))
+	for i := range cells {
+		args[i] = &cells[i]
+	}
+	g := &mapBlogIdentity{}
+	idx0 := map[string]int{}
+	assoc1 := map[string]string{}
+	idx2 := map[string]int{}
+	idx3 := map[string]int{}
+	after := make([][]func() error, 3)
+	out := *dst
+	row := 0
+	for rows.Next() {
+		if err := rows.Scan(args...); err != nil {
+			return err
+		}
+		row++
+		g.reset()
+		k0 := g.identity(nil, &cells[0], &cells[1], &cells[2], &cells[3])
+		i0, ok0 := idx0[string(k0)]
+		if !ok0 {
+			var e Blog
+			if cells[0].IsNull() {
+				return mapBlogError(carta.KindNullToNonNullable, row, "id", "Blog.Id", fmt.Errorf("cannot load NULL into int"))
+			}
+			v0, err := cells[0].Int64()
+			if err != nil {
+				return mapBlogError(carta.KindConversion, row, "id", "Blog.Id", fmt.Errorf("cannot convert to int: %w", err))
+			}
+			e.Id = int(v0)
+			if cells[1].IsNull() {
+				return mapBlogError(carta.KindNullToNonNullable, row, "title", "Blog.Title", fmt.Errorf("cannot load NULL into string"))
+			}
+			v1, err := cells[1].String()
+			if err != nil {
+				return mapBlogError(carta.KindConversion, row, "title", "Blog.Title", fmt.Errorf("cannot convert to string: %w", err))
+			}
+			e.Title = v1
+			if cells[2].IsNull() {
+				return mapBlogError(carta.KindNullToNonNullable, row, "created_at", "Blog.CreatedAt", fmt.Errorf("cannot load NULL into time.Time"))
+			}
+			v2, err := cells[2].Time()
+			if err != nil {
+				return mapBlogError(carta.KindConversion, row, "created_at", "Blog.CreatedAt", fmt.Errorf("cannot convert to time.Time: %w", err))
+			}
+			e.CreatedAt = v2
+			if cells[3].IsNull() {
+				return mapBlogError(carta.KindNullToNonNullable, row, "status", "Blog.Status", fmt.Errorf("cannot load NULL into example.Status"))
+			}
+			v3, err := cells[3].String()
+			if err != nil {
+				return mapBlogError(carta.KindConversion, row, "status", "Blog.Status", fmt.Errorf("cannot convert to example.Status: %w", err))
+			}
+			e.Status = Status(v3)
+			e.Tags = []string{}
+			e.Posts = []Post{}
+			out = append(out, e)
+			i0 = len(out) - 1
+			idx0[string(k0)] = i0
+			after[0] = append(after[0], func() error {
+				return mapBlogAfterMapError("example.Blog", out[i0].AfterCartaMap())
+			})
+		}
+		e0 := &out[i0]
+		if !cells[4].IsNull() {
+			v4, err := cells[4].String()
+			if err != nil {
+				return mapBlogError(carta.KindConversion, row, "tags", fmt.Sprintf("Blog.Tags[%d]", len(e0.Tags)), fmt.Errorf("cannot convert to string: %w", err))
+			}
+			e0.Tags = append(e0.Tags, v4)
+		}
+		if !(cells[5].IsNull() && cells[6].IsNull() && cells[7].IsNull()) {
+			k1 := g.identity(k0, &cells[5], &cells[6], &cells[7])
+			if cur, ok := assoc1[string(k0)]; !ok {
+				assoc1[string(k0)] = string(k1)
+				var e Author
+				if cells[5].IsNull() {
+					return mapBlogError(carta.KindNullToNonNullable, row, "author->id", "Blog.Author.Id", fmt.Errorf("cannot load NULL into int"))
+				}
+				v5, err := cells[5].Int64()
+				if err != nil {
+					return mapBlogError(carta.KindConversion, row, "author->id", "Blog.Author.Id", fmt.Errorf("cannot convert to int: %w", err))
+				}
+				e.Id = int(v5)
+				if cells[6].IsNull() {
+					return mapBlogError(carta.KindNullToNonNullable, row, "author->username", "Blog.Author.Username", fmt.Errorf("cannot load NULL into string"))
+				}
+				v6, err := cells[6].String()
+				if err != nil {
+					return mapBlogError(carta.KindConversion, row, "author->username", "Blog.Author.Username", fmt.Errorf("cannot convert to string: %w", err))
+				}
+				e.Username = v6
+				if !cells[7].IsNull() {
+					v7, err := cells[7].NullString()
+					if err != nil {
+						return mapBlogError(carta.KindConversion, row, "author->email", "Blog.Author.Email", fmt.Errorf("cannot convert to sql.NullString: %w", err))
+					}
+					e.Email = v7
+				}
+				e0.Author = &e
+			} else if cur != string(k1) {
+				return mapBlogError(carta.KindCardinality, row, "", "Blog.Author", fmt.Errorf("rows hold more than one distinct example.Author for a has-one relationship"))
+			}
+		}
+		if !(cells[8].IsNull() && cells[9].IsNull() && cells[10].IsNull()) {
+			k2 := g.identity(k0, &cells[8], &cells[9], &cells[10])
+			i2, ok2 := idx2[string(k2)]
+			if !ok2 {
+				var e Post
+				if cells[8].IsNull() {
+					return mapBlogError(carta.KindNullToNonNullable, row, "posts->id", fmt.Sprintf("Blog.Posts[%d].Id", len(e0.Posts)), fmt.Errorf("cannot load NULL into int"))
+				}
+				v8, err := cells[8].Int64()
+				if err != nil {
+					return mapBlogError(carta.KindConversion, row, "posts->id", fmt.Sprintf("Blog.Posts[%d].Id", len(e0.Posts)), fmt.Errorf("cannot convert to int: %w", err))
+				}
+				e.Id = int(v8)
+				if cells[9].IsNull() {
+					return mapBlogError(carta.KindNullToNonNullable, row, "posts->title", fmt.Sprintf("Blog.Posts[%d].Title", len(e0.Posts)), fmt.Errorf("cannot load NULL into string"))
+				}
+				v9, err := cells[9].String()
+				if err != nil {
+					return mapBlogError(carta.KindConversion, row, "posts->title", fmt.Sprintf("Blog.Posts[%d].Title", len(e0.Posts)), fmt.Errorf("cannot convert to string: %w", err))
+				}
+				e.Title = v9
+				if !cells[10].IsNull() {
+					v10, err := cells[10].Float64()
+					if err != nil {
+						return mapBlogError(carta.KindConversion, row, "posts->score", fmt.Sprintf("Blog.Posts[%d].Score", len(e0.Posts)), fmt.Errorf("cannot convert to float64: %w", err))
+					}
+					x10 := v10
+					e.Score = &x10
+				}
+				e.Comments = []*Comment{}
+				e0.Posts = append(e0.Posts, e)
+				i2 = len(e0.Posts) - 1
+				idx2[string(k2)] = i2
+				after[1] = append(after[1], func() error {
+					return mapBlogAfterMapError("example.Post", out[i0].Posts[i2].AfterCartaMap())
+				})
+			}
+			e2 := &e0.Posts[i2]
+			if !(cells[11].IsNull() && cells[12].IsNull()) {
+				k3 := g.identity(k2, &cells[11], &cells[12])
+				i3, ok3 := idx3[string(k3)]
+				if !ok3 {
+					var e Comment
+					if cells[11].IsNull() {
+						return mapBlogError(carta.KindNullToNonNullable, row, "posts->comments->id", fmt.Sprintf("Blog.Posts[%d].Comments[%d].Id", i2, len(e2.Comments)), fmt.Errorf("cannot load NULL into int"))
+					}
+					v11, err := cells[11].Int64()
+					if err != nil {
+						return mapBlogError(carta.KindConversion, row, "posts->comments->id", fmt.Sprintf("Blog.Posts[%d].Comments[%d].Id", i2, len(e2.Comments)), fmt.Errorf("cannot convert to int: %w", err))
+					}
+					e.Id = int(v11)
+					if cells[12].IsNull() {
+						return mapBlogError(carta.KindNullToNonNullable, row, "posts->comments->body", fmt.Sprintf("Blog.Posts[%d].Comments[%d].Body", i2, len(e2.Comments)), fmt.Errorf("cannot load NULL into string"))
+					}
+					v12, err := cells[12].String()
+					if err != nil {
+						return mapBlogError(carta.KindConversion, row, "posts->comments->body", fmt.Sprintf("Blog.Posts[%d].Comments[%d].Body", i2, len(e2.Comments)), fmt.Errorf("cannot convert to string: %w", err))
+					}
+					e.Body = v12
+					e2.Comments = append(e2.Comments, &e)
+					i3 = len(e2.Comments) - 1
+					idx3[string(k3)] = i3
+					after[2] = append(after[2], func() error {
+						return mapBlogAfterMapError("example.Comment", out[i0].Posts[i2].Comments[i3].AfterCartaMap())
+					})
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for d := len(after) - 1; d >= 0; d-- {
+		for _, f := range after[d] {
+			if err := f(); err != nil {
+				return err
+			}
+		}
+	}
+	*dst = out
+	return nil
+}
+
+func mapBlogError(kind carta.ErrorKind, row int, column string, path string, err error) error {
+	return &carta.MappingError{Kind: kind, Column: column, FieldPath: path, Row: row, Err: err}
+}
+
+func mapBlogAfterMapError(typ string, err error) error {
+	if err != nil {
+		return fmt.Errorf("carta: %s.AfterCartaMap: %w", typ, err)
+	}
+	return nil
+}
+
+// mapBlogIdentity builds the identities of the elements of a row in a single buffer.
+// The identity of an element is prefixed with the identity of its parent, cell ids are self-delimiting
+// so that the identities of different elements cannot be equal
+type mapBlogIdentity struct {
+	buf []byte
+}
+
+func (g *mapBlogIdentity) reset() {
+	g.buf = g.buf[:0]
+}
+
+func (g *mapBlogIdentity) identity(parent []byte, cells ...*value.Cell) []byte {
+	start := len(g.buf)
+	g.buf = append(g.buf, parent...)
+	for _, c := range cells {
+		g.buf = c.AppendUid(g.buf)
+	}
+	return g.buf[start:]
+}
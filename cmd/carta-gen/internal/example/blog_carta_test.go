@@ -0,0 +1,140 @@
+package example
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hackafterdark/carta"
+)
+
+var created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// mapBoth maps the same rows with MapBlog and with carta.Map
+func mapBoth(t *testing.T, rows [][]interface{}) (generated []Blog, generatedErr error, reflected []Blog, reflectedErr error) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 2; i++ {
+		mockRows := sqlmock.NewRows(mapBlogColumns)
+		for _, row := range rows {
+			values := make([]driver.Value, len(row))
+			for j, v := range row {
+				values[j] = v
+			}
+			mockRows.AddRow(values...)
+		}
+		mock.ExpectQuery("SELECT (.+) FROM blogs").WillReturnRows(mockRows)
+	}
+
+	query := func() *sql.Rows {
+		sqlRows, err := db.Query("SELECT * FROM blogs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sqlRows
+	}
+	generatedErr = MapBlog(query(), &generated)
+	reflectedErr = carta.Map(query(), &reflected)
+	return
+}
+
+func TestMapBlogMatchesMap(t *testing.T) {
+	rows := [][]interface{}{
+		// blog 1, two posts, the first with two comments
+		{1, "first", created, "draft", "go", 10, "ann", nil, 100, "hello", 1.5, 1000, "nice"},
+		{1, "first", created, "draft", "sql", 10, "ann", nil, 100, "hello", 1.5, 1001, "thanks"},
+		{1, "first", created, "draft", nil, 10, "ann", nil, 101, "again", nil, nil, nil},
+		// blog 2, no author nor posts
+		{2, "second", created, "published", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+		// blog 3, the author is repeated on every row
+		{3, "third", created, "draft", "go", 12, "eve", "eve@example.com", 102, "hi", 2.0, nil, nil},
+		{3, "third", created, "draft", "go", 12, "eve", "eve@example.com", 102, "hi", 2.0, nil, nil},
+		// the same comment under two posts is loaded into both
+		{3, "third", created, "draft", nil, 12, "eve", "eve@example.com", 103, "bye", nil, 1002, "same"},
+		{3, "third", created, "draft", nil, 12, "eve", "eve@example.com", 102, "hi", 2.0, 1002, "same"},
+	}
+	generated, generatedErr, reflected, reflectedErr := mapBoth(t, rows)
+	if generatedErr != nil || reflectedErr != nil {
+		t.Fatalf("MapBlog: %v, carta.Map: %v", generatedErr, reflectedErr)
+	}
+	if !reflect.DeepEqual(generated, reflected) {
+		t.Errorf("MapBlog and carta.Map differ\nMapBlog:   %+v\ncarta.Map: %+v", generated, reflected)
+	}
+	if len(generated) != 3 || len(generated[0].Posts[0].Comments) != 2 || generated[2].Author.Username != "eve" {
+		t.Errorf("unexpected blogs %+v", generated)
+	}
+	if generated[0].Posts[0].CommentCount != 2 || generated[0].CommentCount != 2 || generated[2].CommentCount != 2 {
+		t.Errorf("expected AfterCartaMap to count comments of posts before blogs, got %+v", generated)
+	}
+}
+
+func TestMapBlogAfterMapError(t *testing.T) {
+	rows := [][]interface{}{
+		{1, "first", created, "draft", nil, nil, nil, nil, 100, "hello", nil, 1000, ""},
+	}
+	generated, generatedErr, _, reflectedErr := mapBoth(t, rows)
+	if !errors.Is(generatedErr, ErrEmptyComment) || !errors.Is(reflectedErr, ErrEmptyComment) {
+		t.Fatalf("expected ErrEmptyComment, got %v and %v", generatedErr, reflectedErr)
+	}
+	if generatedErr.Error() != reflectedErr.Error() {
+		t.Errorf("MapBlog error %q differs from carta.Map error %q", generatedErr, reflectedErr)
+	}
+	if len(generated) != 0 {
+		t.Errorf("expected dst to be left empty, got %+v", generated)
+	}
+}
+
+func TestMapBlogErrorsMatchMap(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []interface{}
+	}{
+		{"null into non nullable", []interface{}{1, nil, created, "draft", nil, nil, nil, nil, nil, nil, nil, nil, nil}},
+		{"conversion", []interface{}{1, "first", created, "draft", nil, nil, nil, nil, 100, "hello", "not a number", nil, nil}},
+		{"nested", []interface{}{1, "first", created, "draft", nil, nil, nil, nil, 100, "hello", nil, 1000, nil}},
+		{"more than one author", []interface{}{1, "first", created, "draft", nil, 10, "ann", nil, 99, "ok", nil, nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := [][]interface{}{
+				{1, "first", created, "draft", nil, 11, "bob", nil, 99, "ok", nil, nil, nil},
+				tt.row,
+			}
+			_, generatedErr, _, reflectedErr := mapBoth(t, rows)
+			var generated, reflected *carta.MappingError
+			if !errors.As(generatedErr, &generated) || !errors.As(reflectedErr, &reflected) {
+				t.Fatalf("expected mapping errors, got %v and %v", generatedErr, reflectedErr)
+			}
+			if generated.Kind != reflected.Kind || generated.Column != reflected.Column ||
+				generated.FieldPath != reflected.FieldPath || generated.Row != reflected.Row {
+				t.Errorf("MapBlog error %q differs from carta.Map error %q", generatedErr, reflectedErr)
+			}
+		})
+	}
+}
+
+func TestMapBlogColumnMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM blogs").WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "first"))
+	rows, err := db.Query("SELECT * FROM blogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blogs []Blog
+	err = MapBlog(rows, &blogs)
+	if !errors.Is(err, carta.ErrColumnMismatch) {
+		t.Errorf("expected a column mismatch, got %v", err)
+	}
+}
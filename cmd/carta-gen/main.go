@@ -0,0 +1,66 @@
+// Command carta-gen generates reflection-free mapping functions for a struct and a fixed set of columns.
+//
+// The generated function is equivalent to carta.Map for a destination of *[]T: columns are matched to fields,
+// and rows are merged into has-one and has-many relationships, with the same naming, delimiter, identity and
+// has-one cardinality rules, and AfterCartaMap is called on structs which implement carta.AfterMapper.
+// Mapping problems, such as columns which are not mapped onto any field, are reported when the code is
+// generated rather than when the query is run.
+//
+// carta-gen is meant to be run with go generate, from the package which declares the type:
+//
+//	//go:generate go run github.com/hackafterdark/carta/cmd/carta-gen -type Blog -columns "id,title,author->id,author->username"
+//
+// which writes the function MapBlog(rows *sql.Rows, dst *[]Blog) error to blog_carta.go.
+//
+// carta-gen supports a subset of the types carta.Map supports: basic kinds and named types of basic kinds,
+// time.Time, sql.NullXXX, math/big numbers, UUIDs ([16]byte), pointers to those, structs, pointers to structs, and slices
+// of any of these. Other arrays, numbered column groups, embedded structs, and types which implement sql.Scanner or
+// encoding.TextUnmarshaler are not supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var (
+		typeName = flag.String("type", "", "name of the struct to map rows onto, required")
+		columns  = flag.String("columns", "", "comma separated columns of the query, in order, required")
+		funcName = flag.String("func", "", "name of the generated function, default Map<type>")
+		output   = flag.String("output", "", "output file, default <type>_carta.go")
+		dir      = flag.String("dir", ".", "directory of the package which declares the type")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: carta-gen -type T -columns c1,c2,... [-func MapT] [-output t_carta.go] [-dir .]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeName == "" || *columns == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *funcName == "" {
+		*funcName = "Map" + *typeName
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_carta.go"
+	}
+
+	cols := strings.Split(*columns, ",")
+	for i := range cols {
+		cols[i] = strings.TrimSpace(cols[i])
+	}
+	src, err := generate(*dir, *typeName, *funcName, cols)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "carta-gen: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "carta-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,18 +1,27 @@
 package carta
 
 import (
-	"database/sql"
 	"fmt"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 // column represents the ith struct field of this mapper where the column is to be mapped
 type column struct {
-	typ         *sql.ColumnType
 	name        string
 	columnIndex int
 	i           fieldIndex
+	element     int // index into the array of a numbered column group, used only if the field is a group
+}
+
+// claim records the field a column was allocated to, it is used to detect columns which match more than one field
+type claim struct {
+	field string // Go path of the field, ie Blog.Author.Id
+	// rank is the number of ancestors the column name leaves out, 0 for the name of a top level field or the fully
+	// qualified name of a nested field (author_id for Blog.Author.Id), 1 for its bare name (id)
+	rank int
 }
 
 // allocateColumns maps result set columns into the given Mapper's fields and its sub-mappers.
@@ -31,42 +40,69 @@ type column struct {
 // the resulting column indexes for m.SortedColumnIndexes and then recursively allocates
 // columns for each sub-map.
 //
+// Fields and sub-maps are visited in struct field order, so the allocation does not depend on map
+// iteration order. A nested field only claims its most specific name which is present, the one which includes
+// the most ancestors: Blog.Posts.Author.Id prefers posts_author_id to author_id, and author_id to id. A name
+// never competes with a column already claimed through a more specific name. A column which is the best match
+// of two fields at the same rank, or a field which matches more than one column at its best rank, is an
+// ErrAmbiguousColumn error.
+//
 // The function mutates the Mapper structures and the input columns map. It returns any
 // error returned by recursive allocation or an error when the IsBasic column constraint
 // is violated.
 func allocateColumns(m *Mapper, columns map[string]column) error {
+	return allocateColumnsAt(m, columns, map[string]claim{}, typeName(m.Typ))
+}
+
+func allocateColumnsAt(m *Mapper, columns map[string]column, claims map[string]claim, path string) error {
 	presentColumns := map[string]column{}
 	if m.IsBasic {
 		if len(m.AncestorNames) == 0 {
 			// Top-level basic mapper: must map exactly one column overall
 			if len(columns) != 1 {
-				return fmt.Errorf(
-					"carta: when mapping to a slice of a basic type, "+
+				return newMappingError(KindColumnMismatch, m.Typ,
+					"when mapping to a slice of a basic type, "+
 						"the query must return exactly one column (got %d)",
 					len(columns),
 				)
 			}
 			for cName, c := range columns {
 				presentColumns[cName] = column{
-					typ:         c.typ,
 					name:        cName,
 					columnIndex: c.columnIndex,
 				}
+				claims[cName] = claim{field: path}
 				delete(columns, cName)
 				break
 			}
 		} else {
-			// Nested basic mapper: pick exactly one matching ancestor-qualified column
-			candidates := getColumnNameCandidates("", m.AncestorNames, m.Delimiter)
+			// Nested basic mapper: pick exactly one matching ancestor-qualified column, the most specific one present
 			var matched []string
-			for cName := range columns {
-				if candidates[cName] {
-					matched = append(matched, cName)
+			matchedRank := 0
+			for rank, candidates := range rankedColumnNameCandidates([]string{""}, m.AncestorNames, m.Delimiter) {
+				for _, cName := range sortedCandidates(candidates) {
+					if c, ok := claims[cName]; ok {
+						if c.rank < rank {
+							continue // the column belongs to the field which claimed it by a more specific name
+						}
+						return ambiguousColumnError(m.Typ, cName, path, c.field)
+					}
+					if _, ok := columns[cName]; ok {
+						matched = append(matched, cName)
+					}
+				}
+				if len(matched) != 0 {
+					matchedRank = rank
+					break
 				}
 			}
 			if len(matched) != 1 {
-				return fmt.Errorf(
-					"carta: basic sub-mapper for %v expected exactly one matching column "+
+				kind := KindColumnMismatch
+				if len(matched) > 1 {
+					kind = KindAmbiguousColumn
+				}
+				return newMappingError(kind, m.Typ,
+					"basic sub-mapper for %v expected exactly one matching column "+
 						"(ancestors %v), got %d matches",
 					m.Typ, m.AncestorNames, len(matched),
 				)
@@ -74,31 +110,39 @@ func allocateColumns(m *Mapper, columns map[string]column) error {
 			cName := matched[0]
 			c := columns[cName]
 			presentColumns[cName] = column{
-				typ:         c.typ,
 				name:        cName,
 				columnIndex: c.columnIndex,
 			}
+			claims[cName] = claim{field: path, rank: matchedRank}
 			delete(columns, cName)
 		}
 	} else {
-		for i, field := range m.Fields {
+		for n := 0; n < m.Typ.NumField(); n++ {
+			i := fieldIndex(n)
+			field, ok := m.Fields[i]
+			if !ok {
+				continue
+			}
 			subMap, isSubMap := m.SubMaps[i]
 			delimiter := m.Delimiter
 			if isSubMap {
 				delimiter = subMap.Delimiter
 			}
-			candidates := getColumnNameCandidates(field.Name, m.AncestorNames, delimiter)
+			fieldPath := path + "." + m.Typ.Field(n).Name
 			// can only allocate columns to basic fields
-			if isBasicType(field.Typ) {
-				for cName, c := range columns {
-					if _, ok := candidates[cName]; ok {
-						presentColumns[cName] = column{
-							typ:         c.typ,
-							name:        cName,
-							columnIndex: c.columnIndex,
-							i:           i,
-						}
-						delete(columns, cName) // dealocate claimed column
+			if field.isBasic() {
+				if err := claimColumn(m, presentColumns, columns, claims, fieldNames(field.Name, field.MappedName), delimiter, i, 0, fieldPath); err != nil {
+					return err
+				}
+			} else if field.Group > 0 {
+				for e := 0; e < field.Group; e++ {
+					elemPath := fieldPath + "[" + strconv.Itoa(e) + "]"
+					names := []string{groupColumnName(field.Name, e)}
+					if field.MappedName != "" {
+						names = append(names, groupColumnName(field.MappedName, e))
+					}
+					if err := claimColumn(m, presentColumns, columns, claims, names, delimiter, i, e, elemPath); err != nil {
+						return err
 					}
 				}
 			}
@@ -121,15 +165,106 @@ func allocateColumns(m *Mapper, columns map[string]column) error {
 		ancestorNames = m.AncestorNames
 	}
 
-	for i, subMap := range m.SubMaps {
-		subMap.AncestorNames = append(ancestorNames, m.Fields[i].Name)
-		if err := allocateColumns(subMap, columns); err != nil {
+	for _, i := range m.sortedSubMapIndexes() {
+		subMap := m.SubMaps[i]
+		subMap.AncestorNames = append(ancestorNames[:len(ancestorNames):len(ancestorNames)], m.Fields[i].Name)
+		if err := allocateColumnsAt(subMap, columns, claims, path+"."+m.Typ.Field(int(i)).Name); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// fieldNames returns the names a field is matched by, its name and the name given to it by a FieldNamer, if any
+func fieldNames(name string, mappedName string) []string {
+	if mappedName == "" {
+		return []string{name}
+	}
+	return []string{name, mappedName}
+}
+
+// claimColumn allocates the column matching the field known by names to the ith field (and element of a numbered column group)
+func claimColumn(m *Mapper, presentColumns, columns map[string]column, claims map[string]claim, names []string, delimiter string, i fieldIndex, element int, path string) error {
+	for rank, candidates := range rankedColumnNameCandidates(names, m.AncestorNames, delimiter) {
+		var matched []string
+		for _, cName := range sortedCandidates(candidates) {
+			if c, ok := claims[cName]; ok {
+				if c.rank < rank {
+					continue // the column belongs to the field which claimed it by a more specific name
+				}
+				return ambiguousColumnError(m.Fields[i].Typ, cName, path, c.field)
+			}
+			if _, ok := columns[cName]; ok {
+				matched = append(matched, cName)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if len(matched) > 1 {
+			return &MappingError{
+				Kind:      KindAmbiguousColumn,
+				FieldPath: path,
+				GoType:    m.Fields[i].Typ,
+				Err:       fmt.Errorf("field matches more than one column: %s", strings.Join(matched, ", ")),
+			}
+		}
+		cName := matched[0]
+		c := columns[cName]
+		presentColumns[cName] = column{
+			name:        cName,
+			columnIndex: c.columnIndex,
+			i:           i,
+			element:     element,
+		}
+		claims[cName] = claim{field: path, rank: rank}
+		delete(columns, cName) // dealocate claimed column
+		return nil
+	}
+	return nil
+}
+
+// rankedColumnNameCandidates returns the column names of a field known by names, grouped by the number of ancestors
+// they leave out: the fully qualified names first and the bare names last
+func rankedColumnNameCandidates(names []string, ancestorNames []string, delimiter string) []map[string]bool {
+	ranked := make([]map[string]bool, len(ancestorNames)+1)
+	seen := map[string]bool{}
+	// the candidates of ancestorNames[rank:] include those of the shorter suffixes, which leave out more ancestors
+	for rank := len(ancestorNames); rank >= 0; rank-- {
+		candidates := map[string]bool{}
+		for _, name := range names {
+			for cName := range getColumnNameCandidates(name, ancestorNames[rank:], delimiter) {
+				if !seen[cName] {
+					seen[cName] = true
+					candidates[cName] = true
+				}
+			}
+		}
+		ranked[rank] = candidates
+	}
+	return ranked
+}
+
+func ambiguousColumnError(typ reflect.Type, column string, path string, otherPath string) error {
+	return &MappingError{
+		Kind:      KindAmbiguousColumn,
+		Column:    column,
+		FieldPath: path,
+		GoType:    typ,
+		Err:       fmt.Errorf("column is also matched by %s", otherPath),
+	}
+}
+
+// sortedSubMapIndexes returns the field indexes of the sub maps in struct field order
+func (m *Mapper) sortedSubMapIndexes() []fieldIndex {
+	indexes := make([]fieldIndex, 0, len(m.SubMaps))
+	for i := range m.SubMaps {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(a, b int) bool { return indexes[a] < indexes[b] })
+	return indexes
+}
+
 func getColumnNameCandidates(fieldName string, ancestorNames []string, delimiter string) map[string]bool {
 	// empty field name means that the mapper is basic, since there is no struct assiciated with this slice, there is no field name
 	candidates := map[string]bool{}
@@ -1,7 +1,10 @@
 package carta
 
 import (
+	"database/sql/driver"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -185,3 +188,197 @@ func TestAllocateColumnsDeeplyNested(t *testing.T) {
 		t.Errorf("expected 'posts_labels_name' column to be present in submap")
 	}
 }
+
+type BlogWithAuthorID struct {
+	ID       int    `db:"id"`
+	AuthorID int    `db:"author_id"`
+	Author   Author `carta:"author"`
+}
+
+type BlogWithAuthorAndEditor struct {
+	ID     int    `db:"id"`
+	Author Author `carta:"author"`
+	Editor Author `carta:"editor"`
+}
+
+type PostWithAuthor struct {
+	Id     int
+	Author Author
+}
+
+type BlogWithAuthorAndPostAuthors struct {
+	Id     int
+	Author Author
+	Posts  []PostWithAuthor
+}
+
+type BlogWithAuthorAndPosts struct {
+	Id     int              `db:"id"`
+	Author Author           `carta:"author"`
+	Posts  []PostWithAuthor `carta:"posts"`
+}
+
+func allocateTestColumns(t *testing.T, dst interface{}, names ...string) (*Mapper, error) {
+	t.Helper()
+	m, err := newMapper(reflect.TypeOf(dst))
+	if err != nil {
+		t.Fatalf("error creating new mapper: %s", err)
+	}
+	if err := determineFieldsNames(m); err != nil {
+		t.Fatalf("error determining field names: %s", err)
+	}
+	columns := map[string]column{}
+	for i, name := range names {
+		columns[name] = column{name: name, columnIndex: i}
+	}
+	return m, allocateColumns(m, columns)
+}
+
+func TestAllocateColumnsAmbiguousParentAndChild(t *testing.T) {
+	_, err := allocateTestColumns(t, &[]BlogWithAuthorID{}, "id", "author_id", "author_name")
+	if !errors.Is(err, ErrAmbiguousColumn) {
+		t.Fatalf("expected an ambiguous column error, got %v", err)
+	}
+	for _, field := range []string{"BlogWithAuthorID.AuthorID", "BlogWithAuthorID.Author.ID"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error to name %s, got %q", field, err.Error())
+		}
+	}
+}
+
+func TestAllocateColumnsAmbiguousBareName(t *testing.T) {
+	// "name" is the bare name of both Author.Name and Editor.Name
+	_, err := allocateTestColumns(t, &[]BlogWithAuthorAndEditor{}, "id", "author_id", "editor_id", "name")
+	if !errors.Is(err, ErrAmbiguousColumn) {
+		t.Fatalf("expected an ambiguous column error, got %v", err)
+	}
+}
+
+func TestAllocateColumnsQualifiedNamePreferred(t *testing.T) {
+	m, err := allocateTestColumns(t, &[]BlogWithPosts{}, "id", "title", "posts_id", "posts_title")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	postsSubMap := m.SubMaps[2]
+	if _, ok := postsSubMap.PresentColumns["posts_title"]; !ok {
+		t.Errorf("expected 'posts_title' column to be present in submap")
+	}
+	if _, ok := postsSubMap.PresentColumns["title"]; ok {
+		t.Errorf("expected bare 'title' column not to be claimed when 'posts_title' matches")
+	}
+}
+
+func TestAllocateColumnsParentClaimsBareName(t *testing.T) {
+	// "id" is claimed by Blog.ID, the bare name of Blog.Author.ID does not compete with it
+	m, err := allocateTestColumns(t, &[]Blog{}, "id", "title", "author_id", "author_name")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := m.PresentColumns["id"]; !ok {
+		t.Errorf("expected 'id' column to be present in Blog")
+	}
+	if _, ok := m.SubMaps[2].PresentColumns["author_id"]; !ok {
+		t.Errorf("expected 'author_id' column to be present in Author")
+	}
+}
+
+func TestAllocateColumnsDeterministic(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		m, err := allocateTestColumns(t, &[]BlogWithPosts{}, "id", "name", "posts_id", "posts_title", "posts_labels_id", "posts_labels_name")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(m.SubMaps[2].AncestorNames, []string{"posts"}) ||
+			!reflect.DeepEqual(m.SubMaps[2].SubMaps[2].AncestorNames, []string{"posts", "labels"}) {
+			t.Fatalf("unexpected ancestor names")
+		}
+		if !reflect.DeepEqual(m.SubMaps[2].SortedColumnIndexes, []int{2, 3}) {
+			t.Fatalf("expected Posts identity columns [2 3], got %v", m.SubMaps[2].SortedColumnIndexes)
+		}
+	}
+}
+
+func TestAllocateColumnsFullyQualifiedNested(t *testing.T) {
+	// author_id is the full name of Blog.Author.Id and only a shorter name of Blog.Posts.Author.Id,
+	// whose full name posts_author_id is present
+	for _, dst := range []interface{}{&[]BlogWithAuthorAndPostAuthors{}, &[]BlogWithAuthorAndPosts{}} {
+		m, err := allocateTestColumns(t, dst, "id", "author_id", "posts_id", "posts_author_id")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		author := m.SubMaps[1]
+		posts := m.SubMaps[2]
+		if _, ok := author.PresentColumns["author_id"]; !ok {
+			t.Errorf("expected 'author_id' column to be present in Author, got %v", author.PresentColumns)
+		}
+		if _, ok := posts.PresentColumns["posts_id"]; !ok {
+			t.Errorf("expected 'posts_id' column to be present in Posts, got %v", posts.PresentColumns)
+		}
+		if _, ok := posts.SubMaps[1].PresentColumns["posts_author_id"]; !ok {
+			t.Errorf("expected 'posts_author_id' column to be present in Posts.Author, got %v", posts.SubMaps[1].PresentColumns)
+		}
+	}
+	if _, err := Explain([]string{"id", "author_id", "posts_id", "posts_author_id"}, &[]BlogWithAuthorAndPostAuthors{}); err != nil {
+		t.Errorf("unexpected Explain error: %s", err)
+	}
+
+	rows := queryRows(t, []string{"id", "author_id", "posts_id", "posts_author_id"}, []driver.Value{1, 10, 100, 20})
+	var blogs []BlogWithAuthorAndPostAuthors
+	if err := Map(rows, &blogs); err != nil {
+		t.Fatal(err)
+	}
+	if len(blogs) != 1 || blogs[0].Author.ID != 10 || len(blogs[0].Posts) != 1 || blogs[0].Posts[0].Author.ID != 20 {
+		t.Errorf("expected blog author 10 and post author 20, got %+v", blogs)
+	}
+}
+
+func TestAllocateColumnsMoreSpecificClaimKept(t *testing.T) {
+	// without posts_author_id, author_id is the best match of Blog.Posts.Author.Id, but it leaves out an ancestor
+	// and does not compete with Blog.Author.Id, which claimed it by its full name
+	m, err := allocateTestColumns(t, &[]BlogWithAuthorAndPostAuthors{}, "id", "author_id", "posts_id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(m.SubMaps[2].SubMaps[1].PresentColumns) != 0 {
+		t.Errorf("expected Posts.Author to have no columns, got %v", m.SubMaps[2].SubMaps[1].PresentColumns)
+	}
+}
+
+type PostWithUntaggedTags struct {
+	ID   int
+	Tags []string
+}
+
+type BlogWithTagsAndPosts struct {
+	ID    int
+	Tags  []string
+	Posts []PostWithUntaggedTags
+}
+
+func TestAllocateColumnsNestedBasicQualifiedName(t *testing.T) {
+	// tags is the full name of Blog.Tags and only a shorter name of Blog.Posts.Tags, whose full name posts_tags is present
+	columns := []string{"id", "tags", "posts_id", "posts_tags"}
+	m, err := allocateTestColumns(t, &[]BlogWithTagsAndPosts{}, columns...)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := m.SubMaps[1].PresentColumns["tags"]; !ok {
+		t.Errorf("expected 'tags' column to be present in Tags, got %v", m.SubMaps[1].PresentColumns)
+	}
+	if _, ok := m.SubMaps[2].SubMaps[1].PresentColumns["posts_tags"]; !ok {
+		t.Errorf("expected 'posts_tags' column to be present in Posts.Tags, got %v", m.SubMaps[2].SubMaps[1].PresentColumns)
+	}
+	if _, err := Explain(columns, &[]BlogWithTagsAndPosts{}); err != nil {
+		t.Errorf("unexpected Explain error: %s", err)
+	}
+
+	rows := queryRows(t, columns, []driver.Value{1, "a", 10, "x"})
+	var blogs []BlogWithTagsAndPosts
+	if err := Map(rows, &blogs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []BlogWithTagsAndPosts{{ID: 1, Tags: []string{"a"}, Posts: []PostWithUntaggedTags{{ID: 10, Tags: []string{"x"}}}}}
+	if !reflect.DeepEqual(blogs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, blogs)
+	}
+}
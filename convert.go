@@ -0,0 +1,319 @@
+package carta
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hackafterdark/carta/value"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// cellSetter loads cells into a field of a single type. Setters are compiled once, when the mapper is built,
+// so that loading a row does not need to work out how to convert each cell.
+type cellSetter struct {
+	typ   reflect.Type // type the cell is converted to, the pointed to type if the field is a pointer
+	isPtr bool         // the field is a pointer to typ, NULL leaves it nil
+
+	// set converts a non NULL cell into dst, an addressable value of typ
+	set func(dst reflect.Value, c *value.Cell) error
+	// setNull loads NULL into dst, nil if NULL cannot be loaded into typ
+	setNull func(dst reflect.Value) error
+}
+
+// compileSetter returns the setter of a basic type, see isBasicType, or nil if typ is not basic.
+// In order of precedence, a type is loaded with the converter registered for it, as one of the types known
+// to carta (time.Time, sql.NullXXX, ...), with its sql.Scanner or encoding.TextUnmarshaler implementation, or by its kind.
+// UUID types which implement sql.Scanner are loaded by carta from UNIQUEIDENTIFIER columns.
+func compileSetter(typ reflect.Type) *cellSetter {
+	s := &cellSetter{typ: typ}
+	if typ.Kind() == reflect.Ptr {
+		s.typ = typ.Elem()
+		s.isPtr = true
+	}
+
+	if c := typeConverter(s.typ); c != nil {
+		return c.compile(s)
+	}
+
+	if basicTyp, ok := value.BasicTypes[s.typ]; ok {
+		if _, nullable := value.NullableTypes[s.typ]; nullable {
+			s.setNull = setZero
+		}
+		switch basicTyp {
+		case value.Time:
+			s.set = setTime
+		case value.Timestamp:
+			s.set = setTimestamp
+		case value.NullBool:
+			s.set = setNullBool
+		case value.NullFloat64:
+			s.set = setNullFloat64
+		case value.NullInt32:
+			s.set = setNullInt32
+		case value.NullInt64:
+			s.set = setNullInt64
+		case value.NullString:
+			s.set = setNullString
+		case value.NullTime:
+			s.set = setNullTime
+		case value.BigInt:
+			s.set = setBigInt
+		case value.BigRat:
+			s.set = setBigRat
+		case value.BigFloat:
+			s.set = setBigFloat
+		}
+		return s
+	}
+
+	if reflect.PtrTo(s.typ).Implements(scannerType) {
+		s.set = setScanner
+		if value.IsUUIDType(s.typ) {
+			s.set = setScannerUUID
+		}
+		s.setNull = setScannerNull
+		return s
+	}
+	if reflect.PtrTo(s.typ).Implements(textUnmarshalerType) {
+		s.set = setTextUnmarshaler
+		return s
+	}
+
+	switch s.typ.Kind() {
+	case reflect.Bool:
+		s.set = setBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.set = setInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.set = setUint
+	case reflect.Float32, reflect.Float64:
+		s.set = setFloat
+	case reflect.String:
+		s.set = setString
+	case reflect.Array:
+		if !value.IsUUIDType(s.typ) {
+			return nil
+		}
+		s.set = setUUID
+	default:
+		return nil
+	}
+	return s
+}
+
+// isSettable reports whether a setter can be compiled for t, ie t has a registered converter, or is a sql.Scanner
+// or encoding.TextUnmarshaler. t is the type of a field or the type it points to, a pointer to a pointer is not settable
+func isSettable(t reflect.Type) bool {
+	return typeConverter(t) != nil || reflect.PtrTo(t).Implements(scannerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func setZero(dst reflect.Value) error {
+	return nil
+}
+
+func setBool(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Bool()
+	if err != nil {
+		return err
+	}
+	dst.SetBool(d)
+	return nil
+}
+
+func setInt(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Int64()
+	if err != nil {
+		return err
+	}
+	dst.SetInt(d)
+	return nil
+}
+
+func setUint(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Uint64()
+	if err != nil {
+		return err
+	}
+	dst.SetUint(d)
+	return nil
+}
+
+func setFloat(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Float64()
+	if err != nil {
+		return err
+	}
+	dst.SetFloat(d)
+	return nil
+}
+
+func setString(dst reflect.Value, c *value.Cell) error {
+	d, err := c.String()
+	if err != nil {
+		return err
+	}
+	dst.SetString(d)
+	return nil
+}
+
+func setTime(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Time()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*time.Time) = d
+	return nil
+}
+
+// setTimestamp sets the fields of the timestamp in place, timestamps hold a mutex and must not be copied
+func setTimestamp(dst reflect.Value, c *value.Cell) error {
+	d, err := c.Time()
+	if err != nil {
+		return err
+	}
+	ts := dst.Addr().Interface().(*timestamppb.Timestamp)
+	if d.IsZero() {
+		ts.Seconds, ts.Nanos = 0, 0
+		return nil
+	}
+	ts.Seconds, ts.Nanos = d.Unix(), int32(d.Nanosecond())
+	return nil
+}
+
+func setNullBool(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullBool()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullBool) = d
+	return nil
+}
+
+func setNullFloat64(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullFloat64()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullFloat64) = d
+	return nil
+}
+
+func setNullInt32(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullInt32()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullInt32) = d
+	return nil
+}
+
+func setNullInt64(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullInt64()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullInt64) = d
+	return nil
+}
+
+func setNullString(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullString()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullString) = d
+	return nil
+}
+
+func setNullTime(dst reflect.Value, c *value.Cell) error {
+	d, err := c.NullTime()
+	if err != nil {
+		return err
+	}
+	*dst.Addr().Interface().(*sql.NullTime) = d
+	return nil
+}
+
+// big numbers are parsed from the text of NUMERIC and DECIMAL columns, without going through a float64
+func setBigInt(dst reflect.Value, c *value.Cell) error {
+	d, err := c.BigInt()
+	if err != nil {
+		return err
+	}
+	dst.Addr().Interface().(*big.Int).Set(d)
+	return nil
+}
+
+func setBigRat(dst reflect.Value, c *value.Cell) error {
+	d, err := c.BigRat()
+	if err != nil {
+		return err
+	}
+	dst.Addr().Interface().(*big.Rat).Set(d)
+	return nil
+}
+
+func setBigFloat(dst reflect.Value, c *value.Cell) error {
+	d, err := c.BigFloat()
+	if err != nil {
+		return err
+	}
+	dst.Addr().Interface().(*big.Float).Set(d)
+	return nil
+}
+
+func setUUID(dst reflect.Value, c *value.Cell) error {
+	d, err := c.UUID()
+	if err != nil {
+		return err
+	}
+	reflect.Copy(dst, reflect.ValueOf(d[:]))
+	return nil
+}
+
+// setScanner passes the value the driver scanned into the cell to Scan, as database/sql would, NULL is passed as nil.
+// []byte values are copied, since the cell reuses its buffer for the next row
+func setScanner(dst reflect.Value, c *value.Cell) error {
+	src := c.Raw()
+	if b, ok := src.([]byte); ok {
+		src = append([]byte(nil), b...)
+	}
+	return dst.Addr().Interface().(sql.Scanner).Scan(src)
+}
+
+// setScannerUUID loads UUID types such as github.com/google/uuid.UUID with their Scan method, except from the
+// mixed-endian binary form of UNIQUEIDENTIFIER columns, which their Scan method cannot recognize
+func setScannerUUID(dst reflect.Value, c *value.Cell) error {
+	if strings.EqualFold(c.DatabaseTypeName(), value.UniqueIdentifier) {
+		return setUUID(dst, c)
+	}
+	return setScanner(dst, c)
+}
+
+func setScannerNull(dst reflect.Value) error {
+	return dst.Addr().Interface().(sql.Scanner).Scan(nil)
+}
+
+func setTextUnmarshaler(dst reflect.Value, c *value.Cell) error {
+	var text string
+	if c.Kind() == reflect.String {
+		text, _ = c.String()
+	} else {
+		src, err := c.AsInterface()
+		if err != nil {
+			return err
+		}
+		text = fmt.Sprint(src)
+	}
+	return dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+}
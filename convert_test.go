@@ -0,0 +1,419 @@
+package carta
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// Money is loaded with its sql.Scanner implementation
+type Money struct {
+	Cents int64
+	Valid bool
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+	case int64:
+		*m = Money{Cents: v, Valid: true}
+	case string:
+		var dollars, cents int64
+		if _, err := fmt.Sscanf(v, "%d.%d", &dollars, &cents); err != nil {
+			return err
+		}
+		*m = Money{Cents: dollars*100 + cents, Valid: true}
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Level is loaded with its encoding.TextUnmarshaler implementation rather than as an int
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level " + string(text))
+	}
+	return nil
+}
+
+type Order struct {
+	ID       int       `db:"id"`
+	Total    Money     `db:"total"`
+	Discount *Money    `db:"discount"`
+	Priority Level     `db:"priority"`
+	Placed   time.Time `db:"placed"`
+}
+
+func TestCompileSetter(t *testing.T) {
+	testCases := []struct {
+		typ      reflect.Type
+		isPtr    bool
+		nullable bool
+	}{
+		{typ: reflect.TypeOf(0)},
+		{typ: reflect.TypeOf(new(string)), isPtr: true},
+		{typ: reflect.TypeOf(time.Time{})},
+		{typ: reflect.TypeOf(sql.NullString{}), nullable: true},
+		{typ: reflect.TypeOf(Money{}), nullable: true},
+		{typ: reflect.TypeOf(Level(0))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.typ.String(), func(t *testing.T) {
+			s := compileSetter(tc.typ)
+			if s == nil {
+				t.Fatalf("expected a setter for %v", tc.typ)
+			}
+			if s.isPtr != tc.isPtr {
+				t.Errorf("expected isPtr %v, got %v", tc.isPtr, s.isPtr)
+			}
+			if (s.setNull != nil) != tc.nullable {
+				t.Errorf("expected nullable %v, got %v", tc.nullable, s.setNull != nil)
+			}
+		})
+	}
+
+	if s := compileSetter(reflect.TypeOf(map[string]int{})); s != nil {
+		t.Errorf("expected no setter for a map")
+	}
+}
+
+func TestMapScannerAndTextUnmarshaler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	placed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "total", "discount", "priority", "placed"}).
+		AddRow(1, "12.34", nil, "high", placed).
+		AddRow(2, int64(500), int64(50), "low", placed)
+	mock.ExpectQuery("SELECT (.+) FROM orders").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orders []Order
+	if err := Map(sqlRows, &orders); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Order{
+		{ID: 1, Total: Money{Cents: 1234, Valid: true}, Priority: 2, Placed: placed},
+		{ID: 2, Total: Money{Cents: 500, Valid: true}, Discount: &Money{Cents: 50, Valid: true}, Priority: 1, Placed: placed},
+	}
+	if !reflect.DeepEqual(orders, expected) {
+		t.Errorf("expected %+v, got %+v", expected, orders)
+	}
+}
+
+// binaryID only accepts the []byte values of BINARY(16) columns, as the Scan method of github.com/google/uuid.UUID does
+type binaryID [16]byte
+
+func (id *binaryID) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok || len(b) != 16 {
+		return fmt.Errorf("cannot scan %T into binaryID", src)
+	}
+	copy(id[:], b)
+	return nil
+}
+
+// blob keeps the slice passed to Scan
+type blob []byte
+
+func (b *blob) Scan(src interface{}) error {
+	v, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into blob", src)
+	}
+	*b = v
+	return nil
+}
+
+func TestMapScannerDriverValues(t *testing.T) {
+	type File struct {
+		ID   binaryID `db:"id"`
+		Data blob     `db:"data"`
+		Size Money    `db:"size"`
+	}
+	first := []byte("0123456789abcdef")
+	second := []byte("fedcba9876543210")
+	rows := queryRows(t, []string{"id", "data", "size"},
+		[]driver.Value{first, []byte("first"), int64(5)},
+		[]driver.Value{second, []byte("other"), int64(5)},
+	)
+	var files []File
+	if err := Map(rows, &files); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	if string(files[0].ID[:]) != string(first) || string(files[1].ID[:]) != string(second) {
+		t.Errorf("expected ids %q and %q, got %q and %q", first, second, files[0].ID[:], files[1].ID[:])
+	}
+	// the bytes passed to Scan are not overwritten by the next row
+	if string(files[0].Data) != "first" || string(files[1].Data) != "other" {
+		t.Errorf("expected data %q and %q, got %q and %q", "first", "other", files[0].Data, files[1].Data)
+	}
+}
+
+func TestMapScannerErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []driver.Value
+		sentinel error
+	}{
+		{
+			name:     "Scanner error",
+			values:   []driver.Value{1, 1.5, nil, "low", time.Now()},
+			sentinel: ErrConversion,
+		},
+		{
+			name:     "TextUnmarshaler error",
+			values:   []driver.Value{1, int64(1), nil, "medium", time.Now()},
+			sentinel: ErrConversion,
+		},
+		{
+			name:     "TextUnmarshaler NULL",
+			values:   []driver.Value{1, int64(1), nil, nil, time.Now()},
+			sentinel: ErrNullToNonNullable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"id", "total", "discount", "priority", "placed"}).AddRow(tc.values...)
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+			sqlRows, err := db.Query("SELECT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var orders []Order
+			if err := Map(sqlRows, &orders); !errors.Is(err, tc.sentinel) {
+				t.Errorf("expected error to match %v, got %v", tc.sentinel, err)
+			}
+		})
+	}
+}
+
+func TestMapBasicSliceOfScanners(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"total"}).AddRow(int64(100)).AddRow(nil)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var totals []Money
+	if err := Map(sqlRows, &totals); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Money{{Cents: 100, Valid: true}, {}}
+	if !reflect.DeepEqual(totals, expected) {
+		t.Errorf("expected %+v, got %+v", expected, totals)
+	}
+}
+
+func TestMapBigNumbers(t *testing.T) {
+	type Account struct {
+		ID      big.Int    `db:"id"`
+		Balance *big.Rat   `db:"balance"`
+		Rate    *big.Float `db:"rate"`
+		Amount  string     `db:"amount"`
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// NUMERIC columns arrive as text, their digits are kept rather than rounded through a float64
+	rows := sqlmock.NewRows([]string{"id", "balance", "rate", "amount"}).
+		AddRow("18446744073709551617", "0.1", "1.000000000000000000001", "12345678901234567890.12").
+		AddRow(int64(2), nil, nil, "0")
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accounts []Account
+	if err := Map(sqlRows, &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+	a := accounts[0]
+	if a.ID.String() != "18446744073709551617" {
+		t.Errorf("expected id 18446744073709551617, got %v", &a.ID)
+	}
+	if a.Balance == nil || a.Balance.RatString() != "1/10" {
+		t.Errorf("expected a balance of 1/10, got %v", a.Balance)
+	}
+	if a.Rate == nil || a.Rate.Text('f', 21) != "1.000000000000000000001" {
+		t.Errorf("expected a rate of 1.000000000000000000001, got %v", a.Rate)
+	}
+	if a.Amount != "12345678901234567890.12" {
+		t.Errorf("expected the amount to keep its digits, got %q", a.Amount)
+	}
+	if b := accounts[1]; b.ID.Int64() != 2 || b.Balance != nil || b.Rate != nil {
+		t.Errorf("expected NULL to leave big number pointers nil, got %+v", b)
+	}
+}
+
+type testUUID [16]byte
+
+func TestMapUUIDs(t *testing.T) {
+	type Account struct {
+		ID     testUUID  `db:"id"`
+		Parent *testUUID `db:"parent"`
+		Owner  [16]byte  `db:"owner"`
+	}
+	expected := testUUID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	mixedEndian := []byte{0x78, 0x56, 0x34, 0x12, 0xbc, 0x9a, 0xf0, 0xde, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+	testCases := []struct {
+		name   string
+		dbType string
+		id     driver.Value
+	}{
+		{"postgres uuid", "UUID", "12345678-9abc-def0-0123-456789abcdef"},
+		{"mysql binary", "BINARY", expected[:]},
+		{"hex text", "CHAR", "123456789ABCDEF00123456789ABCDEF"},
+		{"sql server uniqueidentifier", "UNIQUEIDENTIFIER", mixedEndian},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRowsWithColumnDefinition(
+				sqlmock.NewColumn("id").OfType(tc.dbType, tc.id),
+				sqlmock.NewColumn("parent").OfType(tc.dbType, tc.id),
+				sqlmock.NewColumn("owner").OfType(tc.dbType, tc.id),
+			).AddRow(tc.id, nil, tc.id)
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+			sqlRows, err := db.Query("SELECT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var accounts []Account
+			if err := Map(sqlRows, &accounts); err != nil {
+				t.Fatal(err)
+			}
+			if len(accounts) != 1 {
+				t.Fatalf("expected 1 account, got %d", len(accounts))
+			}
+			a := accounts[0]
+			if a.ID != expected || a.Owner != [16]byte(expected) || a.Parent != nil {
+				t.Errorf("expected id and owner %x without a parent, got %x, %x and %v", expected, a.ID, a.Owner, a.Parent)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		rows := queryRows(t, []string{"id"}, []driver.Value{"not-a-uuid"})
+		var accounts []Account
+		if err := Map(rows, &accounts); !errors.Is(err, ErrConversion) {
+			t.Errorf("expected ErrConversion, got %v", err)
+		}
+	})
+}
+
+func TestMapScannerUUIDs(t *testing.T) {
+	expected := binaryID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	mixedEndian := []byte{0x78, 0x56, 0x34, 0x12, 0xbc, 0x9a, 0xf0, 0xde, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+	// binaryID is loaded with its Scan method, except from UNIQUEIDENTIFIER columns
+	for dbType, id := range map[string][]byte{"BINARY": expected[:], "UNIQUEIDENTIFIER": mixedEndian} {
+		t.Run(dbType, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRowsWithColumnDefinition(sqlmock.NewColumn("id").OfType(dbType, id)).AddRow(id)
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+			sqlRows, err := db.Query("SELECT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []binaryID
+			if err := Map(sqlRows, &ids); err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != 1 || ids[0] != expected {
+				t.Errorf("expected %x, got %x", expected, ids)
+			}
+		})
+	}
+}
+
+func TestMapIgnoresPointersToPointers(t *testing.T) {
+	type User struct {
+		ID    int              `db:"id"`
+		Name  **sql.NullString `db:"name"`
+		Total **convMoney      `db:"total"`
+		Level **Level          `db:"level"`
+	}
+	rows := queryRows(t, []string{"id", "name", "total", "level"}, []driver.Value{1, "ann", "1.00", "high"})
+	var users []User
+	if err := Map(rows, &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != 1 || users[0].Name != nil || users[0].Total != nil || users[0].Level != nil {
+		t.Errorf("expected fields of pointers to pointers to be left nil, got %+v", users)
+	}
+}
+
+func TestMapUUIDDestination(t *testing.T) {
+	id := []byte("0123456789abcdef")
+	for _, dst := range []interface{}{new(testUUID), new(binaryID)} {
+		rows := queryRows(t, []string{"id"}, []driver.Value{id})
+		err := Map(rows, dst)
+		if !errors.Is(err, ErrInvalidDestination) || !strings.Contains(err.Error(), "is a basic type") {
+			t.Errorf("%T: expected ErrInvalidDestination, got %v", dst, err)
+		}
+	}
+
+	rows := queryRows(t, []string{"id"}, []driver.Value{id})
+	var ids []testUUID
+	if err := Map(rows, &ids); err != nil || len(ids) != 1 || string(ids[0][:]) != string(id) {
+		t.Errorf("expected a slice of UUIDs to be mapped, got %x, err: %v", ids, err)
+	}
+}
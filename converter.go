@@ -0,0 +1,123 @@
+package carta
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/hackafterdark/carta/value"
+)
+
+// ConvOption is the db tag option which selects a named converter for a field, ie `db:"price,conv=cents"`
+const ConvOption = "conv"
+
+// converter loads non NULL cells into a type with a function registered by RegisterConverter or RegisterNamedConverter
+type converter struct {
+	typ reflect.Type
+	set func(dst reflect.Value, c *value.Cell) error
+}
+
+var converters = struct {
+	sync.RWMutex
+	byType map[reflect.Type]*converter
+	byName map[string]*converter
+}{
+	byType: map[reflect.Type]*converter{},
+	byName: map[string]*converter{},
+}
+
+func newConverter[T any](fn func(c *value.Cell) (T, error)) *converter {
+	return &converter{
+		typ: reflect.TypeOf((*T)(nil)).Elem(),
+		set: func(dst reflect.Value, c *value.Cell) error {
+			d, err := fn(c)
+			if err != nil {
+				return err
+			}
+			*dst.Addr().Interface().(*T) = d
+			return nil
+		},
+	}
+}
+
+// RegisterConverter registers the function which loads columns into fields of type T, or *T, in place of the
+// conversions built into carta. T becomes a basic type, so a struct such as Money is mapped from a single column
+// rather than as a has-one relationship.
+//
+// fn is only called for non NULL values, NULL leaves *T fields nil and cannot be loaded into a T unless T is a
+// sql.NullXXX type. Converters apply to mappers generated after they are registered, register them before calling Map,
+// ie in an init function:
+//
+//	carta.RegisterConverter(func(c *value.Cell) (Money, error) {
+//		s, err := c.String()
+//		if err != nil {
+//			return Money{}, err
+//		}
+//		return ParseMoney(s)
+//	})
+func RegisterConverter[T any](fn func(c *value.Cell) (T, error)) {
+	c := newConverter(fn)
+	converters.Lock()
+	defer converters.Unlock()
+	converters.byType[c.typ] = c
+}
+
+// RegisterNamedConverter registers a converter which is only used by the fields which select it with the conv
+// db tag option, ie `db:"price,conv=cents"`. It takes precedence over the converter registered for the type and the
+// conversions built into carta, and follows the same NULL rules as RegisterConverter.
+func RegisterNamedConverter[T any](name string, fn func(c *value.Cell) (T, error)) {
+	c := newConverter(fn)
+	converters.Lock()
+	defer converters.Unlock()
+	converters.byName[name] = c
+}
+
+// typeConverter returns the converter registered for typ, if any
+func typeConverter(typ reflect.Type) *converter {
+	converters.RLock()
+	defer converters.RUnlock()
+	return converters.byType[typ]
+}
+
+// compileFieldSetter returns the setter of the field fieldName of type typ, which selects the converter conv
+// with its db tag, or the setter compiled for typ if conv is empty
+func compileFieldSetter(typ reflect.Type, fieldName string, conv string) (*cellSetter, error) {
+	if conv == "" {
+		return compileSetter(typ), nil
+	}
+	converters.RLock()
+	c, ok := converters.byName[conv]
+	converters.RUnlock()
+	if !ok {
+		return nil, newMappingError(KindInvalidDestination, typ, "no converter named %q is registered for field %s", conv, fieldName)
+	}
+	s := &cellSetter{typ: typ}
+	if typ.Kind() == reflect.Ptr {
+		s.typ = typ.Elem()
+		s.isPtr = true
+	}
+	if c.typ != s.typ {
+		return nil, newMappingError(KindInvalidDestination, typ, "converter %q loads %v, which cannot be set into field %s of type %v", conv, c.typ, fieldName, typ)
+	}
+	return c.compile(s), nil
+}
+
+// compile sets the conversion functions of s, a setter of the type of c
+func (c *converter) compile(s *cellSetter) *cellSetter {
+	s.set = c.set
+	if _, nullable := value.NullableTypes[s.typ]; nullable {
+		s.setNull = setZero
+	}
+	return s
+}
+
+// converterName returns the converter selected by the conv option of the db tag of f, if any
+func converterName(f reflect.StructField) string {
+	parts := strings.Split(f.Tag.Get(DbTagKey), ",")
+	for _, part := range parts[1:] {
+		if key, val, ok := strings.Cut(strings.TrimSpace(part), "="); ok && key == ConvOption {
+			return val
+		}
+	}
+	return ""
+}
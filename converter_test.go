@@ -0,0 +1,174 @@
+package carta
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hackafterdark/carta/value"
+)
+
+// convMoney is registered with RegisterConverter, it is loaded from NUMERIC text such as "12.34"
+type convMoney struct {
+	Cents int64
+}
+
+// convCode is a string type whose named converter trims the padding of bpchar columns
+type convCode string
+
+func init() {
+	RegisterConverter(func(c *value.Cell) (convMoney, error) {
+		s, err := c.String()
+		if err != nil {
+			return convMoney{}, err
+		}
+		units, fraction, _ := strings.Cut(s, ".")
+		cents, err := strconv.ParseInt(units+(fraction + "00")[:2], 10, 64)
+		if err != nil {
+			return convMoney{}, fmt.Errorf("invalid amount %q", s)
+		}
+		return convMoney{Cents: cents}, nil
+	})
+	RegisterNamedConverter("trim", func(c *value.Cell) (string, error) {
+		s, err := c.String()
+		return strings.TrimRight(s, " "), err
+	})
+	RegisterNamedConverter("code", func(c *value.Cell) (convCode, error) {
+		s, err := c.String()
+		return convCode(strings.ToUpper(strings.TrimSpace(s))), err
+	})
+	RegisterNamedConverter("cents", func(c *value.Cell) (int64, error) {
+		f, err := c.Float64()
+		return int64(f*100 + 0.5), err
+	})
+}
+
+type convOrder struct {
+	ID       int        `db:"id"`
+	Total    convMoney  `db:"total"`
+	Discount *convMoney `db:"discount"`
+	Customer string     `db:"customer,conv=trim"`
+	Country  *convCode  `db:"country,conv=code"`
+	Tax      int64      `db:"tax, conv=cents"`
+}
+
+func queryRows(t *testing.T, columns []string, values ...[]driver.Value) *sql.Rows {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	rows := sqlmock.NewRows(columns)
+	for _, v := range values {
+		rows.AddRow(v...)
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sqlRows
+}
+
+func TestMapConverters(t *testing.T) {
+	columns := []string{"id", "total", "discount", "customer", "country", "tax"}
+	rows := queryRows(t, columns,
+		[]driver.Value{1, "12.34", "0.5", "ann       ", " fr ", 1.25},
+		[]driver.Value{2, "7", nil, "bob", nil, 0.1},
+	)
+
+	var orders []convOrder
+	if err := Map(rows, &orders); err != nil {
+		t.Fatal(err)
+	}
+	fr := convCode("FR")
+	expected := []convOrder{
+		{ID: 1, Total: convMoney{1234}, Discount: &convMoney{50}, Customer: "ann", Country: &fr, Tax: 125},
+		{ID: 2, Total: convMoney{700}, Customer: "bob", Tax: 10},
+	}
+	if !reflect.DeepEqual(orders, expected) {
+		t.Errorf("expected %+v, got %+v", expected, orders)
+	}
+}
+
+func TestMapConverterBasicSlice(t *testing.T) {
+	rows := queryRows(t, []string{"total"}, []driver.Value{"1.5"}, []driver.Value{"2"})
+
+	var totals []convMoney
+	if err := Map(rows, &totals); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(totals, []convMoney{{150}, {200}}) {
+		t.Errorf("expected totals of 150 and 200 cents, got %+v", totals)
+	}
+}
+
+func TestMapConverterErrors(t *testing.T) {
+	t.Run("conversion", func(t *testing.T) {
+		rows := queryRows(t, []string{"id", "total"}, []driver.Value{1, "lots"})
+		var orders []convOrder
+		err := Map(rows, &orders)
+		if !errors.Is(err, ErrConversion) {
+			t.Fatalf("expected ErrConversion, got %v", err)
+		}
+		expected := `carta: row 1: convOrder.Total (column total): cannot convert to carta.convMoney: invalid amount "lots"`
+		if err.Error() != expected {
+			t.Errorf("expected error %q, got %q", expected, err.Error())
+		}
+	})
+	t.Run("null", func(t *testing.T) {
+		rows := queryRows(t, []string{"id", "total"}, []driver.Value{1, nil})
+		var orders []convOrder
+		if err := Map(rows, &orders); !errors.Is(err, ErrNullToNonNullable) {
+			t.Errorf("expected ErrNullToNonNullable, got %v", err)
+		}
+	})
+	t.Run("unknown converter", func(t *testing.T) {
+		type order struct {
+			Total int64 `db:"total,conv=unknown"`
+		}
+		rows := queryRows(t, []string{"total"}, []driver.Value{1})
+		var orders []order
+		err := Map(rows, &orders)
+		if !errors.Is(err, ErrInvalidDestination) || !strings.Contains(err.Error(), `no converter named "unknown"`) {
+			t.Errorf("expected an unknown converter error, got %v", err)
+		}
+	})
+	t.Run("type mismatch", func(t *testing.T) {
+		type order struct {
+			Customer []byte `db:"customer,conv=trim"`
+		}
+		rows := queryRows(t, []string{"customer"}, []driver.Value{"ann"})
+		var orders []order
+		err := Map(rows, &orders)
+		if !errors.Is(err, ErrInvalidDestination) || !strings.Contains(err.Error(), `converter "trim" loads string`) {
+			t.Errorf("expected a converter type error, got %v", err)
+		}
+	})
+}
+
+func TestDirectScanConverters(t *testing.T) {
+	m, _, err := buildMapper(reflect.TypeOf(&[]convOrder{}), []string{"id", "total", "customer", "tax"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanTypes := map[string]reflect.Type{
+		"id":       reflect.TypeOf(0),
+		"total":    reflect.TypeOf(convMoney{}),
+		"customer": reflect.TypeOf(""),
+		"tax":      reflect.TypeOf(int64(0)),
+	}
+	expected := map[string]bool{"id": true, "total": false, "customer": false, "tax": false}
+	for name, col := range m.PresentColumns {
+		if got := m.directScan(col, scanTypes[name], false); got != expected[name] {
+			t.Errorf("column %s: expected direct scan %v, got %v", name, expected[name], got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package carta
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Sentinel errors, use errors.Is to test whether an error returned by carta is of a particular kind
+//
+//	if errors.Is(err, carta.ErrNoRows) {
+//		// 404
+//	}
+var (
+	// ErrNoRows is returned when mapping onto a struct (rather than a slice) and the query returned no rows
+	ErrNoRows = errors.New("carta: no rows in result set")
+
+	// ErrInvalidDestination is matched by errors caused by the Go type rows are mapped onto, ie an unsupported type or tag
+	ErrInvalidDestination = errors.New("carta: invalid destination")
+
+	// ErrColumnMismatch is matched by errors caused by the columns of the result set not fitting the destination
+	ErrColumnMismatch = errors.New("carta: columns do not match destination")
+
+	// ErrAmbiguousColumn is matched by errors caused by a column which could be mapped onto more than one field
+	ErrAmbiguousColumn = errors.New("carta: ambiguous column")
+
+	// ErrNullToNonNullable is matched by errors caused by loading a NULL value into a field which cannot represent it
+	ErrNullToNonNullable = errors.New("carta: cannot load NULL into non-nullable type")
+
+	// ErrConversion is matched by errors caused by a column value which cannot be converted to the type of its field
+	ErrConversion = errors.New("carta: conversion error")
+
+	// ErrCardinality is matched by errors caused by more elements than the destination can hold
+	ErrCardinality = errors.New("carta: too many elements")
+
+	// ErrInternal is matched by errors which indicate a bug in carta
+	ErrInternal = errors.New("carta: internal error")
+)
+
+// ErrorKind classifies a MappingError, each kind corresponds to one of the sentinel errors
+type ErrorKind int
+
+const (
+	KindInvalidDestination ErrorKind = iota + 1
+	KindColumnMismatch
+	KindAmbiguousColumn
+	KindNullToNonNullable
+	KindConversion
+	KindCardinality
+	KindInternal
+)
+
+var kindSentinels = map[ErrorKind]error{
+	KindInvalidDestination: ErrInvalidDestination,
+	KindColumnMismatch:     ErrColumnMismatch,
+	KindAmbiguousColumn:    ErrAmbiguousColumn,
+	KindNullToNonNullable:  ErrNullToNonNullable,
+	KindConversion:         ErrConversion,
+	KindCardinality:        ErrCardinality,
+	KindInternal:           ErrInternal,
+}
+
+var kindNames = map[ErrorKind]string{
+	KindInvalidDestination: "invalid destination",
+	KindColumnMismatch:     "column mismatch",
+	KindAmbiguousColumn:    "ambiguous column",
+	KindNullToNonNullable:  "null to non-nullable",
+	KindConversion:         "conversion",
+	KindCardinality:        "cardinality",
+	KindInternal:           "internal",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// MappingError describes why rows could not be mapped onto the destination.
+// Fields which do not apply to a particular error are left at their zero value.
+//
+//	var mErr *carta.MappingError
+//	if errors.As(err, &mErr) {
+//		log.Println(mErr.Kind, mErr.Column, mErr.FieldPath)
+//	}
+type MappingError struct {
+	Kind      ErrorKind
+	Column    string       // name of the column being mapped
+	FieldPath string       // Go path of the field being mapped, ie Blog.Posts[3].Author.Email
+	GoType    reflect.Type // Go type being mapped onto
+	Row       int          // 1 based row number, 0 if the error is not about a particular row
+	Err       error        // underlying error
+}
+
+func newMappingError(kind ErrorKind, typ reflect.Type, format string, a ...interface{}) *MappingError {
+	return &MappingError{
+		Kind:   kind,
+		GoType: typ,
+		Err:    fmt.Errorf(format, a...),
+	}
+}
+
+func (e *MappingError) Error() string {
+	var b strings.Builder
+	b.WriteString("carta: ")
+	if e.Row > 0 {
+		fmt.Fprintf(&b, "row %d: ", e.Row)
+	}
+	switch {
+	case e.FieldPath != "" && e.Column != "":
+		fmt.Fprintf(&b, "%s (column %s): ", e.FieldPath, e.Column)
+	case e.FieldPath != "":
+		fmt.Fprintf(&b, "%s: ", e.FieldPath)
+	case e.Column != "":
+		fmt.Fprintf(&b, "column %s: ", e.Column)
+	}
+	if e.Err != nil {
+		b.WriteString(e.Err.Error())
+	} else {
+		b.WriteString(e.Kind.String())
+	}
+	return b.String()
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error of e's kind
+func (e *MappingError) Is(target error) bool {
+	sentinel, ok := kindSentinels[e.Kind]
+	return ok && sentinel == target
+}
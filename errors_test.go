@@ -0,0 +1,174 @@
+package carta
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMappingError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      *MappingError
+		expected string
+	}{
+		{
+			name: "Column error",
+			err: &MappingError{
+				Kind:      KindNullToNonNullable,
+				Column:    "posts_author_email",
+				FieldPath: "Blog.Posts[3].Author.Email",
+				GoType:    reflect.TypeOf(""),
+				Row:       812,
+				Err:       errors.New("cannot load NULL into string"),
+			},
+			expected: "carta: row 812: Blog.Posts[3].Author.Email (column posts_author_email): cannot load NULL into string",
+		},
+		{
+			name: "Column without field path",
+			err: &MappingError{
+				Kind:   KindAmbiguousColumn,
+				Column: "author_id",
+				Err:    errors.New("matches more than one field"),
+			},
+			expected: "carta: column author_id: matches more than one field",
+		},
+		{
+			name:     "Without underlying error",
+			err:      &MappingError{Kind: KindCardinality},
+			expected: "carta: cardinality",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err.Error() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, tc.err.Error())
+			}
+		})
+	}
+}
+
+func TestMappingErrorIs(t *testing.T) {
+	err := error(&MappingError{Kind: KindConversion, Err: &strconv.NumError{Func: "ParseInt", Num: "x", Err: strconv.ErrSyntax}})
+	if !errors.Is(err, ErrConversion) {
+		t.Errorf("expected error to match ErrConversion")
+	}
+	if errors.Is(err, ErrNullToNonNullable) {
+		t.Errorf("expected error not to match ErrNullToNonNullable")
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected error to wrap strconv.ErrSyntax")
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("expected error to wrap *strconv.NumError")
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		columns  []string
+		values   []driver.Value
+		dst      func() interface{}
+		sentinel error
+		kind     ErrorKind
+	}{
+		{
+			name:     "Null to non-nullable",
+			columns:  []string{"ID", "Name"},
+			values:   []driver.Value{1, nil},
+			dst:      func() interface{} { return &[]User{} },
+			sentinel: ErrNullToNonNullable,
+			kind:     KindNullToNonNullable,
+		},
+		{
+			name:     "Conversion",
+			columns:  []string{"ID", "Name"},
+			values:   []driver.Value{"one", "John Doe"},
+			dst:      func() interface{} { return &[]User{} },
+			sentinel: ErrConversion,
+			kind:     KindConversion,
+		},
+		{
+			name:     "Too many columns for a basic slice",
+			columns:  []string{"tag", "extra"},
+			values:   []driver.Value{"tag1", "x"},
+			dst:      func() interface{} { return &[]string{} },
+			sentinel: ErrColumnMismatch,
+			kind:     KindColumnMismatch,
+		},
+		{
+			name:     "Non pointer destination",
+			columns:  []string{"ID", "Name"},
+			values:   []driver.Value{1, "John Doe"},
+			dst:      func() interface{} { return []User{} },
+			sentinel: ErrInvalidDestination,
+			kind:     KindInvalidDestination,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows(tc.columns).AddRow(tc.values...)
+			mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+			sqlRows, err := db.Query("SELECT")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Map(sqlRows, tc.dst())
+			if !errors.Is(err, tc.sentinel) {
+				t.Fatalf("expected error to match %v, got %v", tc.sentinel, err)
+			}
+			var mErr *MappingError
+			if !errors.As(err, &mErr) {
+				t.Fatalf("expected a *MappingError, got %T", err)
+			}
+			if mErr.Kind != tc.kind {
+				t.Errorf("expected kind %v, got %v", tc.kind, mErr.Kind)
+			}
+		})
+	}
+}
+
+func TestMapNoRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(sqlmock.NewRows([]string{"ID", "Name"}))
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(sqlmock.NewRows([]string{"ID", "Name"}))
+
+	sqlRows, err := db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user User
+	if err := Map(sqlRows, &user); !errors.Is(err, ErrNoRows) {
+		t.Errorf("expected ErrNoRows when mapping no rows onto a struct, got %v", err)
+	}
+
+	sqlRows, err = db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []User
+	if err := Map(sqlRows, &users); err != nil {
+		t.Errorf("expected no error when mapping no rows onto a slice, got %v", err)
+	}
+}
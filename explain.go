@@ -0,0 +1,181 @@
+package carta
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Plan describes how the columns of a result set are mapped onto a destination, as computed by Explain.
+// A Plan can be printed as text with String, or as JSON with encoding/json.
+type Plan struct {
+	Root             *PlanNode `json:"root"`
+	UnclaimedColumns []string  `json:"unclaimed_columns"` // columns which are not mapped onto any field
+}
+
+// PlanNode describes the mapping of the destination or one of its has-one/has-many relationships
+type PlanNode struct {
+	Path            string      `json:"path"` // Go path of the node, ie Blog.Posts
+	Type            string      `json:"type"` // Go type of the node, ie []*carta.Post
+	Cardinality     string      `json:"cardinality"`
+	Basic           bool        `json:"basic,omitempty"` // collection of a basic type, every row is a new element
+	Delimiter       string      `json:"delimiter"`
+	AncestorNames   []string    `json:"ancestor_names,omitempty"`
+	IdentityColumns []string    `json:"identity_columns"` // columns which determine whether a row holds a new element
+	Fields          []PlanField `json:"fields,omitempty"`
+	Children        []*PlanNode `json:"children,omitempty"`
+
+	// exported fields which carta cannot map, ie maps, channels or functions
+	UnsupportedFields []string `json:"unsupported_fields,omitempty"`
+}
+
+// PlanField describes the mapping of a basic field
+type PlanField struct {
+	Name       string   `json:"name"` // Go field name, elements of numbered column groups are named Phones[0]
+	Type       string   `json:"type"`
+	Column     string   `json:"column,omitempty"` // column mapped onto the field, empty if none matched
+	Candidates []string `json:"candidates"`       // column names which would have matched the field
+}
+
+// Explain computes how a result set with the given columns would be mapped onto dst, without running a query.
+// dst is the same value that would be passed to Map, ie &[]Blog{}.
+//
+// Explain is a debugging aid, it answers questions such as why the author_id column landed
+// on Blog.AuthorId rather than Blog.Author.Id
+func Explain(columns []string, dst interface{}) (*Plan, error) {
+	dstTyp := reflect.TypeOf(dst)
+	if dstTyp == nil {
+		return nil, newMappingError(KindInvalidDestination, nil, "cannot explain mapping onto nil")
+	}
+	m, unclaimed, err := buildMapper(dstTyp, columns, nil)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		Root:             explainNode(m, typeName(m.Typ), dstTyp.String(), columns),
+		UnclaimedColumns: []string{},
+	}
+	for _, c := range columns {
+		if _, ok := unclaimed[c]; ok {
+			plan.UnclaimedColumns = append(plan.UnclaimedColumns, c)
+		}
+	}
+	return plan, nil
+}
+
+func explainNode(m *Mapper, path string, typ string, columns []string) *PlanNode {
+	node := &PlanNode{
+		Path:            path,
+		Type:            typ,
+		Cardinality:     m.Crd.String(),
+		Basic:           m.IsBasic,
+		Delimiter:       m.Delimiter,
+		AncestorNames:   m.AncestorNames,
+		IdentityColumns: []string{},
+	}
+	for _, i := range m.SortedColumnIndexes {
+		node.IdentityColumns = append(node.IdentityColumns, columns[i])
+	}
+
+	if m.IsBasic {
+		f := PlanField{
+			Name:       typeName(m.Typ),
+			Type:       m.Typ.String(),
+			Candidates: sortedCandidates(getColumnNameCandidates("", m.AncestorNames, m.Delimiter)),
+		}
+		for cName := range m.PresentColumns {
+			f.Column = cName
+		}
+		if len(m.AncestorNames) == 0 {
+			f.Candidates = []string{} // top level basic mappers take the only column
+		}
+		node.Fields = append(node.Fields, f)
+		return node
+	}
+
+	for i := 0; i < m.Typ.NumField(); i++ {
+		field, ok := m.Fields[fieldIndex(i)]
+		if !ok {
+			continue
+		}
+		goField := m.Typ.Field(i)
+		if subMap, isSubMap := m.SubMaps[fieldIndex(i)]; isSubMap {
+			node.Children = append(node.Children, explainNode(subMap, path+"."+goField.Name, goField.Type.String(), columns))
+			continue
+		}
+		if field.isBasic() {
+			node.Fields = append(node.Fields, explainField(m, fieldIndex(i), 0, goField.Name, field.Name, field.Typ))
+		} else if field.Group > 0 {
+			for n := 0; n < field.Group; n++ {
+				name := fmt.Sprintf("%s[%d]", goField.Name, n)
+				node.Fields = append(node.Fields, explainField(m, fieldIndex(i), n, name, groupColumnName(field.Name, n), field.Typ.Elem()))
+			}
+		} else {
+			node.UnsupportedFields = append(node.UnsupportedFields, goField.Name)
+		}
+	}
+	return node
+}
+
+func explainField(m *Mapper, i fieldIndex, element int, goName string, name string, typ reflect.Type) PlanField {
+	f := PlanField{
+		Name:       goName,
+		Type:       typ.String(),
+		Candidates: sortedCandidates(getColumnNameCandidates(name, m.AncestorNames, m.Delimiter)),
+	}
+	for cName, c := range m.PresentColumns {
+		if c.i == i && c.element == element {
+			f.Column = cName
+		}
+	}
+	return f
+}
+
+func sortedCandidates(candidates map[string]bool) []string {
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String renders the plan as an indented tree, one line per node and field
+func (p *Plan) String() string {
+	var b strings.Builder
+	p.Root.write(&b, "")
+	if len(p.UnclaimedColumns) > 0 {
+		fmt.Fprintf(&b, "unclaimed columns: %s\n", strings.Join(p.UnclaimedColumns, ", "))
+	}
+	return b.String()
+}
+
+func (n *PlanNode) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s%s: %s (%s", indent, n.Path, n.Type, n.Cardinality)
+	if n.Basic {
+		b.WriteString(", basic")
+	}
+	fmt.Fprintf(b, ", delimiter %q)\n", n.Delimiter)
+	indent += "  "
+	if len(n.IdentityColumns) > 0 {
+		fmt.Fprintf(b, "%sidentity columns: %s\n", indent, strings.Join(n.IdentityColumns, ", "))
+	}
+	for _, f := range n.Fields {
+		column := f.Column
+		if column == "" {
+			column = "no column"
+		}
+		fmt.Fprintf(b, "%s%s %s: %s", indent, f.Name, f.Type, column)
+		if len(f.Candidates) > 0 {
+			fmt.Fprintf(b, " (tried: %s)", strings.Join(f.Candidates, ", "))
+		}
+		b.WriteString("\n")
+	}
+	for _, name := range n.UnsupportedFields {
+		fmt.Fprintf(b, "%s%s: unsupported type\n", indent, name)
+	}
+	for _, child := range n.Children {
+		child.write(b, indent)
+	}
+}
@@ -0,0 +1,92 @@
+package carta
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	plan, err := Explain([]string{"id", "title", "author_id", "author_name", "extra"}, &[]Blog{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	root := plan.Root
+	if root.Path != "Blog" || root.Cardinality != "collection" {
+		t.Errorf("unexpected root node %+v", root)
+	}
+	if !reflect.DeepEqual(root.IdentityColumns, []string{"id", "title"}) {
+		t.Errorf("expected identity columns [id title], got %v", root.IdentityColumns)
+	}
+	if len(root.Fields) != 2 || root.Fields[0].Column != "id" || root.Fields[1].Column != "title" {
+		t.Errorf("unexpected root fields %+v", root.Fields)
+	}
+
+	if len(root.Children) != 1 {
+		t.Fatalf("expected 1 child, got %d", len(root.Children))
+	}
+	author := root.Children[0]
+	if author.Path != "Blog.Author" || author.Cardinality != "association" || author.Delimiter != "->" {
+		t.Errorf("unexpected author node %+v", author)
+	}
+	if author.Fields[0].Name != "ID" || author.Fields[0].Column != "author_id" {
+		t.Errorf("expected Blog.Author.ID to be mapped from author_id, got %+v", author.Fields[0])
+	}
+	candidates := strings.Join(author.Fields[0].Candidates, ",")
+	if !strings.Contains(candidates, "author->id") || !strings.Contains(candidates, "author_id") {
+		t.Errorf("expected candidates to contain author->id and author_id, got %s", candidates)
+	}
+
+	if !reflect.DeepEqual(plan.UnclaimedColumns, []string{"extra"}) {
+		t.Errorf("expected unclaimed columns [extra], got %v", plan.UnclaimedColumns)
+	}
+
+	text := plan.String()
+	for _, line := range []string{
+		"Blog: *[]carta.Blog (collection, delimiter \"_\")",
+		"  identity columns: id, title",
+		"    ID int: author_id (tried: ",
+		"unclaimed columns: extra",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("expected text plan to contain %q, got:\n%s", line, text)
+		}
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("unexpected error marshalling plan: %s", err)
+	}
+	var decoded Plan
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error unmarshalling plan: %s", err)
+	}
+	if decoded.Root.Children[0].Fields[1].Column != "author_name" {
+		t.Errorf("expected decoded plan to map Blog.Author.Name from author_name, got %+v", decoded.Root.Children[0].Fields[1])
+	}
+}
+
+func TestExplainNumberedGroup(t *testing.T) {
+	plan, err := Explain([]string{"id", "phone1", "phone3"}, &[]ContactWithPhones{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fields := plan.Root.Fields
+	if len(fields) != 4 {
+		t.Fatalf("expected 4 fields, got %d", len(fields))
+	}
+	if fields[1].Name != "Phones[0]" || fields[1].Column != "phone1" {
+		t.Errorf("unexpected field %+v", fields[1])
+	}
+	if fields[2].Name != "Phones[1]" || fields[2].Column != "" {
+		t.Errorf("unexpected field %+v", fields[2])
+	}
+}
+
+func TestExplainInvalidDestination(t *testing.T) {
+	if _, err := Explain([]string{"id"}, []Blog{}); err == nil {
+		t.Errorf("expected an error when explaining a non pointer destination, got nil")
+	}
+}
@@ -0,0 +1,33 @@
+package carta
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AfterMapper is implemented by structs which compute derived fields once they are mapped, such as totals or sorted views.
+// AfterCartaMap is called on every struct once it and all of its has-one and has-many relationships are populated,
+// children before their parents. Map stops and returns the first error returned by AfterCartaMap.
+//
+//	func (b *Blog) AfterCartaMap() error {
+//		b.PostCount = len(b.Posts)
+//		return nil
+//	}
+type AfterMapper interface {
+	AfterCartaMap() error
+}
+
+var afterMapperType = reflect.TypeOf((*AfterMapper)(nil)).Elem()
+
+// implementsAfterMapper reports whether t or a pointer to it implements AfterMapper
+func implementsAfterMapper(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(afterMapperType)
+}
+
+// afterMap calls AfterCartaMap on v, an addressable element of m
+func (m *Mapper) afterMap(v reflect.Value) error {
+	if err := v.Addr().Interface().(AfterMapper).AfterCartaMap(); err != nil {
+		return fmt.Errorf("carta: %v.AfterCartaMap: %w", m.Typ, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package carta
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type hookOrder struct {
+	ID       int            `db:"id"`
+	Customer *hookCustomer  `carta:"customer"`
+	Items    []hookItem     `carta:"items"`
+	Notes    []*hookComment `carta:"notes"`
+	Total    int
+	Calls    int
+}
+
+func (o *hookOrder) AfterCartaMap() error {
+	o.Calls++
+	for _, item := range o.Items {
+		if item.Total == 0 {
+			return errors.New("item mapped after its order")
+		}
+		o.Total += item.Total
+	}
+	return nil
+}
+
+type hookItem struct {
+	ID    int `db:"id"`
+	Qty   int `db:"qty"`
+	Price int `db:"price"`
+	Total int
+}
+
+func (i *hookItem) AfterCartaMap() error {
+	i.Total = i.Qty * i.Price
+	return nil
+}
+
+type hookCustomer struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func (c hookCustomer) AfterCartaMap() error {
+	return nil // value receivers are called on a copy, the hook of a pointer receiver is needed to change fields
+}
+
+var errHook = errors.New("invalid comment")
+
+type hookComment struct {
+	ID   int    `db:"id"`
+	Body string `db:"body"`
+}
+
+func (c *hookComment) AfterCartaMap() error {
+	if c.Body == "" {
+		return errHook
+	}
+	return nil
+}
+
+func TestMapAfterMapper(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "customer_id", "customer_name", "items_id", "items_qty", "items_price", "notes_id", "notes_body"}).
+		AddRow(1, 10, "Ann", 100, 2, 5, 1000, "fragile").
+		AddRow(1, 10, "Ann", 101, 1, 7, 1000, "fragile").
+		AddRow(2, nil, nil, 102, 3, 1, nil, nil)
+	mock.ExpectQuery("SELECT (.+) FROM orders").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var orders []hookOrder
+	if err := Map(sqlRows, &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(orders))
+	}
+	if orders[0].Items[0].Total != 10 || orders[0].Items[1].Total != 7 {
+		t.Errorf("expected item totals 10 and 7, got %+v", orders[0].Items)
+	}
+	if orders[0].Total != 17 || orders[1].Total != 3 {
+		t.Errorf("expected order totals 17 and 3, got %d and %d", orders[0].Total, orders[1].Total)
+	}
+	if orders[0].Calls != 1 || orders[1].Calls != 1 {
+		t.Errorf("expected AfterCartaMap to be called once per order, got %d and %d", orders[0].Calls, orders[1].Calls)
+	}
+}
+
+func TestMapAfterMapperStruct(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "items_id", "items_qty", "items_price"}).
+		AddRow(1, 100, 2, 5).
+		AddRow(1, 101, 4, 1)
+	mock.ExpectQuery("SELECT (.+) FROM orders").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order hookOrder
+	if err := Map(sqlRows, &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 14 || order.Calls != 1 {
+		t.Errorf("expected a total of 14 computed once, got %d computed %d times", order.Total, order.Calls)
+	}
+}
+
+func TestMapAfterMapperFlat(t *testing.T) {
+	for _, opts := range [][]MapOption{nil, {WithoutDeduplication()}} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "qty", "price"}).
+			AddRow(100, 2, 5).
+			AddRow(101, 3, 3)
+		mock.ExpectQuery("SELECT (.+) FROM items").WillReturnRows(rows)
+		sqlRows, err := db.Query("SELECT * FROM items")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var items []*hookItem
+		if err := Map(sqlRows, &items, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 2 || items[0].Total != 10 || items[1].Total != 9 {
+			t.Errorf("expected item totals 10 and 9, got %+v", items)
+		}
+		db.Close()
+	}
+}
+
+func TestMapAfterMapperError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "notes_id", "notes_body"}).
+		AddRow(1, 1000, "fragile").
+		AddRow(1, 1001, "")
+	mock.ExpectQuery("SELECT (.+) FROM orders").WillReturnRows(rows)
+	sqlRows, err := db.Query("SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var orders []hookOrder
+	err = Map(sqlRows, &orders)
+	if !errors.Is(err, errHook) {
+		t.Fatalf("expected the error returned by AfterCartaMap, got %v", err)
+	}
+	expected := "carta: carta.hookComment.AfterCartaMap: invalid comment"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+	if len(orders) != 0 {
+		t.Errorf("expected dst to be left empty, got %+v", orders)
+	}
+}
+
+// hookCalls records the order in which the hooks of hookSibling are called
+var hookCalls []string
+
+type hookSibling struct {
+	Name string `db:"name"`
+}
+
+func (s *hookSibling) AfterCartaMap() error {
+	hookCalls = append(hookCalls, s.Name)
+	return nil
+}
+
+type hookSiblings struct {
+	ID     int           `db:"id"`
+	First  *hookSibling  `carta:"first"`
+	Second []hookSibling `carta:"second"`
+	Third  hookSibling   `carta:"third"`
+	Fourth []hookSibling `carta:"fourth"`
+}
+
+func TestMapAfterMapperOrder(t *testing.T) {
+	expected := []string{"a", "b1", "b2", "c", "d"}
+	for n := 0; n < 20; n++ {
+		rows := queryRows(t, []string{"id", "first_name", "second_name", "third_name", "fourth_name"},
+			[]driver.Value{1, "a", "b1", "c", "d"},
+			[]driver.Value{1, "a", "b2", "c", "d"},
+		)
+		hookCalls = nil
+		var dst []hookSiblings
+		if err := Map(rows, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(hookCalls, expected) {
+			t.Fatalf("expected hooks to be called in struct field order %v, got %v", expected, hookCalls)
+		}
+	}
+}
@@ -1,32 +1,27 @@
 package carta
 
 import (
-	"database/sql"
 	"fmt"
+	"hash/maphash"
 	"reflect"
 	"strconv"
 
 	"github.com/hackafterdark/carta/value"
 )
 
-func (m *Mapper) loadRows(rows *sql.Rows, colTyps []*sql.ColumnType) (*resolver, error) {
-	defer rows.Close() // may not need
+// loadRows loads every row of the current result set into a resolver. Unless keepLast is set, a has-one relationship
+// or struct destination which receives more than one distinct element is an error
+func (m *Mapper) loadRows(rows RowSource, databaseTypeNames []string, keepLast bool) (*resolver, error) {
 	var err error
-	row := make([]interface{}, len(colTyps))
-	colTypNames := make([]string, len(colTyps))
-	for i := 0; i < len(colTyps); i++ {
-		colTypNames[i] = colTyps[i].DatabaseTypeName()
-	}
+	row := m.newRow(databaseTypeNames)
 	rsv := newResolver()
+	path := &fieldPath{}
 	rowCount := 0
 	for rows.Next() {
-		for i := 0; i < len(colTyps); i++ {
-			row[i] = value.NewCell(colTypNames[i])
-		}
-		if err = rows.Scan(row...); err != nil {
+		if err = row.scan(rows, m, rowCount); err != nil {
 			return nil, err
 		}
-		if err = loadRow(m, row, rsv, rowCount); err != nil {
+		if err = loadRowAt(m, row.cells, rsv, rowCount, path, keepLast); err != nil {
 			return nil, err
 		}
 		rowCount++
@@ -37,6 +32,100 @@ func (m *Mapper) loadRows(rows *sql.Rows, colTyps []*sql.ColumnType) (*resolver,
 	return rsv, nil
 }
 
+// scanRow holds the cells a row is scanned into, cells are reused for every row, values are copied out of them
+// into the destination
+type scanRow struct {
+	cells  []interface{} // cells of the mapped columns, discardColumn for the others
+	dest   []interface{} // scan destinations, which scan the cells and record the column which failed
+	failed int           // index of the column whose cell failed to scan, -1 if none did
+}
+
+// newRow returns the cells of a row. Columns which are not mapped onto any field are discarded without being converted
+func (m *Mapper) newRow(databaseTypeNames []string) *scanRow {
+	row := &scanRow{
+		cells: make([]interface{}, len(databaseTypeNames)),
+		dest:  make([]interface{}, len(databaseTypeNames)),
+	}
+	claimed := make([]bool, len(databaseTypeNames))
+	m.markClaimedColumns(claimed)
+	for i := range row.cells {
+		if claimed[i] {
+			cell := value.NewCell(databaseTypeNames[i])
+			row.cells[i] = cell
+			row.dest[i] = cellScanner{cell: cell, column: i, row: row}
+		} else {
+			row.cells[i] = discardColumn{}
+			row.dest[i] = discardColumn{}
+		}
+	}
+	return row
+}
+
+// scan scans the current row of rows. A value which a cell cannot hold is a conversion error of the column and the
+// field it is mapped onto, its path does not index collections as the row is not loaded yet
+func (row *scanRow) scan(rows RowSource, m *Mapper, rowCount int) error {
+	row.failed = -1
+	err := rows.Scan(row.dest...)
+	if err == nil || row.failed < 0 {
+		return err
+	}
+	owner, col, path, ok := m.findColumn(row.failed, typeName(m.Typ))
+	if !ok {
+		return err
+	}
+	s := owner.setter
+	if !owner.IsBasic {
+		s = owner.Fields[col.i].setter
+	}
+	return newColumnError(owner, col, path, rowCount, KindConversion, s.typ, err)
+}
+
+// cellScanner scans a column into its cell, and records the column if the cell cannot hold the value
+type cellScanner struct {
+	cell   *value.Cell
+	column int
+	row    *scanRow
+}
+
+func (s cellScanner) Scan(src interface{}) error {
+	if err := s.cell.Scan(src); err != nil {
+		s.row.failed = s.column
+		return err
+	}
+	return nil
+}
+
+// discardColumn scans columns which are not mapped onto any field, like sql.RawBytes the value is neither copied nor converted
+type discardColumn struct{}
+
+func (discardColumn) Scan(interface{}) error { return nil }
+
+// findColumn returns the mapper of m or its sub maps which loads the column of index columnIndex, the column,
+// and the Go path of that mapper given path, the path of m, ie Blog.Posts.Author
+func (m *Mapper) findColumn(columnIndex int, path string) (*Mapper, column, string, bool) {
+	for _, col := range m.PresentColumns {
+		if col.columnIndex == columnIndex {
+			return m, col, path, true
+		}
+	}
+	for _, i := range m.sortedSubMapIndexes() {
+		if owner, col, subPath, ok := m.SubMaps[i].findColumn(columnIndex, path+"."+m.Typ.Field(int(i)).Name); ok {
+			return owner, col, subPath, true
+		}
+	}
+	return nil, column{}, "", false
+}
+
+// markClaimedColumns sets claimed[i] for every column index mapped onto a field of m or its sub maps
+func (m *Mapper) markClaimedColumns(claimed []bool) {
+	for _, col := range m.PresentColumns {
+		claimed[col.columnIndex] = true
+	}
+	for _, subMap := range m.SubMaps {
+		subMap.markClaimedColumns(claimed)
+	}
+}
+
 // load row maps a single sql row onto a structure that resembles the users struct
 // that mapping is stored in the resolver as a pointer reference to an instance of the struct
 //
@@ -60,8 +149,8 @@ func (m *Mapper) loadRows(rows *sql.Rows, colTyps []*sql.ColumnType) (*resolver,
 // loadRow maps a single scanned SQL row into the resolver using the provided Mapper.
 //
 // It creates or reuses an element in rsv based on a computed unique id:
-// - For basic mappers (m.IsBasic) the id is "row-<rowCount>" (ensures per-row identity).
-// - For non-basic mappers the id is derived from the row values via getUniqueId.
+// - For basic mappers (m.IsBasic) the id is the row number (ensures per-row identity).
+// - For non-basic mappers the id is a hash of the identity column values, see getUniqueId.
 //
 // The function expects row to contain the scanned values as []*value.Cell (passed as []interface{} because sql.Scan requires that shape).
 // For each present column it converts the corresponding Cell into the destination field (handling pointers, nullable types, basic primitives, and known struct wrappers such as Time, NullBool, NullString, etc.).
@@ -69,187 +158,302 @@ func (m *Mapper) loadRows(rows *sql.Rows, colTyps []*sql.ColumnType) (*resolver,
 // After populating the element it initializes per-submap resolvers (if any) and recursively calls loadRow for each non-nil subMap, passing the same rowCount.
 //
 // Returns an error on conversion failures, attempts to load null into non-nullable destinations, or on any recursive loadRow error.
+// Errors name the row number and the Go path of the field that failed, ie "row 3: Blog.Posts[1].Author.Email".
 func loadRow(m *Mapper, row []interface{}, rsv *resolver, rowCount int) error {
+	return loadRowAt(m, row, rsv, rowCount, &fieldPath{}, false)
+}
+
+// loadRowAt is loadRow given the path of the element being loaded, of which the caller sets parent and owner.
+// Paths are reused for every row, the path of sub map elements is held in path.child.
+// keepLast allows associations to receive more than one element, of which setDst keeps the last
+func loadRowAt(m *Mapper, row []interface{}, rsv *resolver, rowCount int, path *fieldPath, keepLast bool) error {
 	var (
-		err      error
-		dstField reflect.Value // destination field to be set with
-		cell     *value.Cell
-		elem     *element
-		found    bool
-		uid      uniqueValId
+		err   error
+		elem  *element
+		found bool
+		uid   uniqueValId
 	)
 
 	if m.IsBasic {
-		uid = uniqueValId("row-" + strconv.Itoa(rowCount))
+		// every row is a new element of a basic slice
+		uid = uniqueValId{uint64(rowCount)}
 	} else {
 		uid = getUniqueId(row, m)
 	}
 
+	path.m, path.rsv, path.uid = m, rsv, uid
+
 	if elem, found = rsv.elements[uid]; !found {
+		if m.Crd == Association && len(rsv.elementOrder) > 0 && !keepLast {
+			// a missing join condition, or a has-many relationship declared as has-one
+			return &MappingError{
+				Kind:      KindCardinality,
+				FieldPath: path.String(),
+				GoType:    m.Typ,
+				Row:       rowCount + 1,
+				Err:       fmt.Errorf("rows hold more than one distinct %v for a has-one relationship", m.Typ),
+			}
+		}
 		// unique row mapping found, new object
 		loadElem := reflect.New(m.Typ).Elem()
+		if err = m.loadElem(row, loadElem, rowCount, path); err != nil {
+			return err
+		}
+		elem = &element{v: loadElem}
+		rsv.elements[uid] = elem
+		rsv.elementOrder = append(rsv.elementOrder, uid)
+	}
 
-		for _, col := range m.PresentColumns {
-			var (
-				kind     reflect.Kind  // kind of destination
-				dst      reflect.Value // destination to set
-				typ      reflect.Type  // underlying type of the destination
-				isDstPtr bool          //is the destination a pointer
-			)
+	for i, subMap := range m.SubMaps {
+		if subMap.isNil(row) {
+			continue
+		}
+		// sub map resolvers are created once a row holds an element of the sub map
+		subRsv, ok := elem.subMaps[i]
+		if !ok {
+			if elem.subMaps == nil {
+				elem.subMaps = make(map[fieldIndex]*resolver, len(m.SubMaps))
+			}
+			subRsv = newResolver()
+			elem.subMaps[i] = subRsv
+		}
+		path.i = i
+		if path.child == nil {
+			path.child = &fieldPath{parent: path}
+		}
+		path.child.owner = m
+		if err = loadRowAt(subMap, row, subRsv, rowCount, path.child, keepLast); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-			cell = row[col.columnIndex].(*value.Cell)
+// loadElem loads the present columns of row into loadElem, a new element of m.
+// Columns which were not scanned into a cell, see loadFlatRows, are skipped
+func (m *Mapper) loadElem(row []interface{}, loadElem reflect.Value, rowCount int, path *fieldPath) error {
+	for _, col := range m.PresentColumns {
+		var (
+			s        *cellSetter   // setter compiled for the destination
+			dst      reflect.Value // destination to set
+			dstField reflect.Value // destination field to be set with
+		)
 
-			if m.IsBasic {
-				dst = loadElem
-				kind = m.Kind
-				typ = m.Typ
-				isDstPtr = m.IsTypePtr
-			} else {
-				dstField = loadElem.Field(int(col.i))
-				if m.Fields[col.i].IsPtr {
-					dst = reflect.New(m.Fields[col.i].ElemTyp).Elem()
-					kind = m.Fields[col.i].ElemKind
-					typ = m.Fields[col.i].ElemTyp
-					isDstPtr = true
-				} else {
-					dst = dstField
-					kind = m.Fields[col.i].Kind
-					typ = m.Fields[col.i].Typ
-					isDstPtr = false
-				}
+		cell, ok := row[col.columnIndex].(*value.Cell)
+		if !ok {
+			continue
+		}
+
+		if m.IsBasic {
+			s = m.setter
+			dst = loadElem
+		} else {
+			field := m.Fields[col.i]
+			s = field.setter
+			dstField = loadElem.Field(int(col.i))
+			if field.Group > 0 {
+				// numbered column group, the column is loaded into a single element of the array
+				dstField = dstField.Index(col.element)
+			}
+			dst = dstField
+			if s.isPtr {
+				dst = reflect.New(s.typ).Elem()
 			}
-			if cell.IsNull() {
-				_, nullable := value.NullableTypes[typ]
-				if !(isDstPtr || nullable) {
-					return fmt.Errorf("carta: cannot load null value to type %s for column %s", typ, col.name)
-				}
+		}
+		if cell.IsNull() {
+			if s.isPtr {
 				// no need to set destination if cell is null
-			} else {
-				switch kind {
-				case reflect.Bool:
-					if d, err := cell.Bool(); err != nil {
-						return value.ConvertsionError(err, typ)
-					} else {
-						dst.SetBool(d)
-					}
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-					if d, err := cell.Uint64(); err != nil {
-						return value.ConvertsionError(err, typ)
-					} else {
-						dst.SetUint(d)
-					}
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					if d, err := cell.Int64(); err != nil {
-						return value.ConvertsionError(err, typ)
-					} else {
-						dst.SetInt(d)
-					}
-				case reflect.String:
-					if d, err := cell.String(); err != nil {
-						return value.ConvertsionError(err, typ)
-					} else {
-						dst.SetString(d)
-					}
-				case reflect.Float32, reflect.Float64:
-					if d, err := cell.Float64(); err != nil {
-						return value.ConvertsionError(err, typ)
-					} else {
-						dst.SetFloat(d)
-					}
-				case reflect.Struct:
-					if strTyp, ok := value.BasicTypes[typ]; ok {
-						// TODO: Type asserion, prevent from calling ValueOf
-						// TODO: make these stupid error checks more concise
-						//  this swich statement should be optimized
-
-						switch strTyp {
-						case value.Time:
-							if d, err := cell.Time(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.Timestamp:
-							if d, err := cell.Timestamp(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullBool:
-							if d, err := cell.NullBool(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullFloat64:
-							if d, err := cell.NullFloat64(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullInt32:
-							if d, err := cell.NullInt32(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullInt64:
-							if d, err := cell.NullInt64(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullString:
-							if d, err := cell.NullString(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						case value.NullTime:
-							if d, err := cell.NullTime(); err != nil {
-								return value.ConvertsionError(err, typ)
-							} else {
-								dst.Set(reflect.ValueOf(d))
-							}
-						}
-					}
-				}
-				if !m.IsBasic && m.Fields[col.i].IsPtr {
-					dstField.Set(dst.Addr())
-				}
+				continue
+			}
+			if s.setNull == nil {
+				return path.columnError(m, col, rowCount, KindNullToNonNullable, s.typ, fmt.Errorf("cannot load NULL into %s", s.typ))
 			}
+			if err := s.setNull(dst); err != nil {
+				return path.columnError(m, col, rowCount, KindConversion, s.typ, fmt.Errorf("cannot convert to %v: %w", s.typ, err))
+			}
+			continue
 		}
-		elem = &element{v: loadElem}
-		if len(m.SubMaps) != 0 {
-			elem.subMaps = map[fieldIndex]*resolver{}
-			for i, _ := range m.SubMaps {
-				elem.subMaps[i] = newResolver()
+		if err := s.set(dst, cell); err != nil {
+			return path.columnError(m, col, rowCount, KindConversion, s.typ, fmt.Errorf("cannot convert to %v: %w", s.typ, err))
+		}
+		if s.isPtr && !m.IsBasic {
+			dstField.Set(dst.Addr())
+		}
+	}
+	return nil
+}
+
+// isFlat reports whether m is a slice without has-one or has-many relationships, whose elements
+// can be appended to the destination as soon as their row is loaded
+func (m *Mapper) isFlat() bool {
+	return m.Crd == Collection && !m.IsArray && len(m.SubMaps) == 0
+}
+
+// loadFlatRows maps the rows of a flat mapper, see isFlat, straight onto dst, a slice, bypassing the resolver and setDst.
+// Duplicate rows are skipped with a set of the unique ids seen so far. Without de-duplication rows are not
+// identified at all, and columns which directScan allows are scanned straight into the new element
+func (m *Mapper) loadFlatRows(rows RowSource, databaseTypeNames []string, dst reflect.Value, dedup bool) error {
+	row := m.newRow(databaseTypeNames)
+	var direct []column // columns scanned straight into the element
+	if scanTyper, ok := rows.(ColumnScanTyper); ok && !dedup {
+		types, nullable, err := scanTyper.ColumnScanTypes()
+		if err != nil {
+			return err
+		}
+		for _, col := range m.PresentColumns {
+			if m.directScan(col, types[col.columnIndex], nullable[col.columnIndex]) {
+				direct = append(direct, col)
+				row.cells[col.columnIndex] = discardColumn{} // loadElem skips the column
 			}
 		}
-		rsv.elements[uid] = elem
-		rsv.elementOrder = append(rsv.elementOrder, uid)
 	}
 
-	for i, subMap := range m.SubMaps {
-		if subMap.isNil(row) {
-			continue
+	var seen map[uniqueValId]struct{}
+	if dedup && !m.IsBasic {
+		seen = map[uniqueValId]struct{}{}
+	}
+	path := &fieldPath{m: m}
+	rowCount := 0
+	// elements are appended to a copy of dst, so that dst is left alone if a row fails to load
+	out := reflect.New(dst.Type()).Elem()
+	out.Set(dst)
+	for rows.Next() {
+		loadElem := reflect.New(m.Typ).Elem()
+		for _, col := range direct {
+			if m.IsBasic {
+				row.dest[col.columnIndex] = loadElem.Addr().Interface()
+			} else {
+				row.dest[col.columnIndex] = loadElem.Field(int(col.i)).Addr().Interface()
+			}
+		}
+		if err := row.scan(rows, m, rowCount); err != nil {
+			return err
+		}
+		if seen != nil {
+			uid := getUniqueId(row.cells, m)
+			if _, found := seen[uid]; found {
+				rowCount++
+				continue
+			}
+			seen[uid] = struct{}{}
 		}
-		if err = loadRow(subMap, row, elem.subMaps[i], rowCount); err != nil {
+		if err := m.loadElem(row.cells, loadElem, rowCount, path); err != nil {
 			return err
 		}
+		if m.isAfterMapper {
+			if err := m.afterMap(loadElem); err != nil {
+				return err
+			}
+		}
+		if m.IsTypePtr {
+			out = reflect.Append(out, loadElem.Addr())
+		} else {
+			out = reflect.Append(out, loadElem)
+		}
+		rowCount++
 	}
-
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	dst.Set(out)
 	return nil
 }
 
-// Generates unique id based on the ancestors of the struct as well as currently considered colum values
+// directScan reports whether col can be scanned by database/sql straight into its field, with the same result
+// as loading it through a cell: the field is the type the driver scans the column into, no converter is registered
+// for it, and NULL is either impossible or loaded into a sql.NullXXX field
+func (m *Mapper) directScan(col column, scanType reflect.Type, nullable bool) bool {
+	typ := m.Typ
+	if !m.IsBasic {
+		field := m.Fields[col.i]
+		if field.Group > 0 || field.Converter != "" {
+			return false
+		}
+		typ = field.Typ
+	}
+	if scanType != typ || typeConverter(typ) != nil {
+		return false
+	}
+	if _, ok := value.NullableTypes[typ]; ok {
+		return true
+	}
+	return !nullable
+}
+
+// fieldPath is the Go path of an element being loaded, ie Blog.Posts[3].Author.
+// It is only rendered when an error is reported, so that building it costs nothing for rows which load successfully
+type fieldPath struct {
+	parent *fieldPath  // path of the parent element, nil for top level elements
+	owner  *Mapper     // mapper of the parent element
+	m      *Mapper     // mapper of this element
+	i      fieldIndex  // while loading sub maps, the index of the sub map field in this element
+	rsv    *resolver   // resolver which holds this element
+	uid    uniqueValId // unique id of this element
+	child  *fieldPath  // path of the sub map element being loaded
+}
+
+func (p *fieldPath) String() string {
+	if p.parent == nil {
+		return typeName(p.m.Typ)
+	}
+	s := p.parent.String() + "." + p.owner.Typ.Field(int(p.parent.i)).Name
+	if p.m.Crd == Collection {
+		index := len(p.rsv.elementOrder) // element is not stored in the resolver yet
+		for n, uid := range p.rsv.elementOrder {
+			if uid == p.uid {
+				index = n
+				break
+			}
+		}
+		s += "[" + strconv.Itoa(index) + "]"
+	}
+	return s
+}
+
+// columnError adds the row number, field path and column name to an error which occurred while loading col
+func (p *fieldPath) columnError(m *Mapper, col column, rowCount int, kind ErrorKind, typ reflect.Type, err error) error {
+	return newColumnError(m, col, p.String(), rowCount, kind, typ, err)
+}
+
+// newColumnError is columnError given the path of the element of m being loaded
+func newColumnError(m *Mapper, col column, path string, rowCount int, kind ErrorKind, typ reflect.Type, err error) error {
+	if !m.IsBasic {
+		path += "." + m.Typ.Field(int(col.i)).Name
+		if m.Fields[col.i].Group > 0 {
+			path += "[" + strconv.Itoa(col.element) + "]"
+		}
+	}
+	return &MappingError{
+		Kind:      kind,
+		Column:    col.name,
+		FieldPath: path,
+		GoType:    typ,
+		Row:       rowCount + 1,
+		Err:       err,
+	}
+}
+
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// seeds of the two halves of uniqueValId, the seeds are random so that ids cannot be forged by crafting column values
+var uidSeeds = [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}
+
+// Generates unique id based on the ancestors of the struct as well as currently considered colum values.
+// Cell ids are self-delimiting, so concatenating them cannot make the ids of two different rows equal,
+// the concatenation is then hashed into a fixed size 128 bit key which is cheap to store and compare.
 func getUniqueId(row []interface{}, m *Mapper) uniqueValId {
-	// TODO: set capacity of the uid slice, using bytes.buffer
-	uid := ""
+	var buf [128]byte
+	uid := buf[:0]
 	for _, i := range m.SortedColumnIndexes {
-		uid = uid + row[i].(*value.Cell).Uid()
+		uid = row[i].(*value.Cell).AppendUid(uid)
 	}
-	return uniqueValId(uid)
+	return uniqueValId{maphash.Bytes(uidSeeds[0], uid), maphash.Bytes(uidSeeds[1], uid)}
 }
 
 func (m *Mapper) isNil(row []interface{}) bool {
@@ -1,11 +1,17 @@
 package carta
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/hackafterdark/carta/value"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -380,3 +386,237 @@ func TestLoadRowTimestamp(t *testing.T) {
 		}
 	}
 }
+
+type Account struct {
+	ID    int64          `db:"id"`
+	Email sql.NullString `db:"email"`
+	Name  string         `db:"name"`
+}
+
+func TestMapFlatDeduplication(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opts     []MapOption
+		expected []Account
+	}{
+		{"default", nil, []Account{{ID: 1, Name: "ann"}, {ID: 2, Name: "bob"}}},
+		{"without deduplication", []MapOption{WithoutDeduplication()}, []Account{{ID: 1, Name: "ann"}, {ID: 1, Name: "ann"}, {ID: 2, Name: "bob"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name"}).
+				AddRow(1, nil, "ann").
+				AddRow(1, nil, "ann").
+				AddRow(2, nil, "bob"))
+			rows, err := db.Query("SELECT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			accounts := []Account{}
+			if err := Map(rows, &accounts, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(accounts, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, accounts)
+			}
+		})
+	}
+}
+
+func TestMapFlatDirectScan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// id and email are scanned straight into their fields, name is nullable and loaded through a cell
+	columns := []*sqlmock.Column{
+		sqlmock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+		sqlmock.NewColumn("email").OfType("VARCHAR", sql.NullString{}).Nullable(true),
+		sqlmock.NewColumn("name").OfType("VARCHAR", "").Nullable(true),
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(mock.NewRowsWithColumnDefinition(columns...).
+		AddRow(int64(1), "ann@example.com", "ann").
+		AddRow(int64(2), nil, "bob"))
+	mock.ExpectQuery("SELECT").WillReturnRows(mock.NewRowsWithColumnDefinition(columns...).
+		AddRow(int64(3), nil, "eve").
+		AddRow(int64(4), nil, nil))
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accounts []*Account
+	if err := Map(rows, &accounts, WithoutDeduplication()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Account{
+		{ID: 1, Email: sql.NullString{String: "ann@example.com", Valid: true}, Name: "ann"},
+		{ID: 2, Name: "bob"},
+	}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, accounts)
+	}
+
+	rows, err = db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Map(rows, &accounts, WithoutDeduplication())
+	if !errors.Is(err, ErrNullToNonNullable) {
+		t.Fatalf("expected NULL name to fail as through a cell, got %v", err)
+	}
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) || mappingErr.FieldPath != "Account.Name" || mappingErr.Row != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Errorf("expected the destination to be left alone on error, got %d accounts", len(accounts))
+	}
+}
+
+func TestDirectScan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	columns := []*sqlmock.Column{
+		sqlmock.NewColumn("id").OfType("BIGINT", int64(0)).Nullable(false),
+		sqlmock.NewColumn("email").OfType("VARCHAR", sql.NullString{}).Nullable(true),
+		sqlmock.NewColumn("name").OfType("VARCHAR", "").Nullable(true),
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(mock.NewRowsWithColumnDefinition(columns...))
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, nullable, err := FromRows(rows).(ColumnScanTyper).ColumnScanTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := buildMapper(reflect.TypeOf(&[]Account{}), []string{"id", "email", "name"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.isFlat() {
+		t.Fatal("expected []Account to be flat")
+	}
+	expected := map[string]bool{"id": true, "email": true, "name": false}
+	for name, col := range m.PresentColumns {
+		if got := m.directScan(col, types[col.columnIndex], nullable[col.columnIndex]); got != expected[name] {
+			t.Errorf("column %s: expected direct scan %v, got %v", name, expected[name], got)
+		}
+	}
+}
+
+// benchConnector is a database/sql driver which returns the same rows for every query,
+// so that benchmarks measure carta rather than a mock's bookkeeping
+type benchConnector struct {
+	columns   []string
+	scanTypes []reflect.Type // optional, the columns are reported as not nullable if set
+	rows      [][]driver.Value
+}
+
+func (c *benchConnector) Connect(context.Context) (driver.Conn, error) { return &benchConn{c}, nil }
+func (c *benchConnector) Driver() driver.Driver                        { return nil }
+
+type benchConn struct{ c *benchConnector }
+
+func (c *benchConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *benchConn) Close() error                        { return nil }
+func (c *benchConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+func (c *benchConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &benchRows{c: c.c}, nil
+}
+
+type benchRows struct {
+	c *benchConnector
+	n int
+}
+
+func (r *benchRows) Columns() []string { return r.c.columns }
+func (r *benchRows) Close() error      { return nil }
+func (r *benchRows) ColumnTypeScanType(i int) reflect.Type {
+	if r.c.scanTypes == nil {
+		return reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+	return r.c.scanTypes[i]
+}
+
+func (r *benchRows) ColumnTypeNullable(i int) (nullable, ok bool) {
+	return false, r.c.scanTypes != nil
+}
+
+func (r *benchRows) Next(dest []driver.Value) error {
+	if r.n == len(r.c.rows) {
+		return io.EOF
+	}
+	copy(dest, r.c.rows[r.n])
+	r.n++
+	return nil
+}
+
+func benchmarkMap[T any](b *testing.B, c *benchConnector, opts ...MapOption) {
+	db := sql.OpenDB(c)
+	defer db.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sqlRows, err := db.Query("SELECT")
+		if err != nil {
+			b.Fatal(err)
+		}
+		var dst []T
+		if err := Map(sqlRows, &dst, opts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(testing.AllocsPerRun(10, func() {
+		sqlRows, _ := db.Query("SELECT")
+		var dst []T
+		Map(sqlRows, &dst, opts...)
+	}))/float64(len(c.rows)), "allocs/row")
+}
+
+// 1000 rows of users, every row is a new user
+func BenchmarkMapFlat(b *testing.B) {
+	rows := make([][]driver.Value, 1000)
+	for i := range rows {
+		rows[i] = []driver.Value{int64(i), []byte("user " + strconv.Itoa(i))}
+	}
+	benchmarkMap[User](b, &benchConnector{columns: []string{"ID", "Name"}, rows: rows})
+}
+
+// BenchmarkMapFlat without de-duplication, the id is scanned straight into its field
+func BenchmarkMapFlatWithoutDeduplication(b *testing.B) {
+	rows := make([][]driver.Value, 1000)
+	for i := range rows {
+		rows[i] = []driver.Value{int64(i), []byte("user " + strconv.Itoa(i))}
+	}
+	benchmarkMap[Account](b, &benchConnector{
+		columns:   []string{"id", "name"},
+		scanTypes: []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf([]byte{})},
+		rows:      rows,
+	}, WithoutDeduplication())
+}
+
+// 1000 rows of 100 blogs with 10 posts each, every row repeats the columns of its blog,
+// the comment column is not mapped onto any field
+func BenchmarkMapNested(b *testing.B) {
+	rows := make([][]driver.Value, 1000)
+	for i := range rows {
+		blog := i / 10
+		rows[i] = []driver.Value{
+			int64(blog), []byte("blog " + strconv.Itoa(blog)),
+			int64(i), []byte("post " + strconv.Itoa(i)),
+			[]byte("a comment which is not mapped"),
+		}
+	}
+	benchmarkMap[BlogWithPosts](b, &benchConnector{columns: []string{"id", "name", "posts_id", "posts_title", "comment"}, rows: rows})
+}
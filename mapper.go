@@ -2,9 +2,9 @@ package carta
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/hackafterdark/carta/value"
@@ -26,16 +26,47 @@ const (
 	Collection
 )
 
+func (c Cardinality) String() string {
+	switch c {
+	case Association:
+		return "association"
+	case Collection:
+		return "collection"
+	}
+	return "unknown"
+}
+
+// OverflowPolicy determines what happens when a has-many relationship mapped onto a fixed size array
+// receives more elements than the array can hold
+type OverflowPolicy int
+
+const (
+	OverflowError    OverflowPolicy = iota // return an error, this is the default
+	OverflowTruncate                       // keep the first len(array) elements, drop the rest
+)
+
 type Field struct {
 	Name string
-	Typ  reflect.Type
-	Kind reflect.Kind
+	// MappedName is the name given to the field by the FieldNamer the mapper was built with, if any,
+	// columns matching either Name or MappedName are mapped onto the field
+	MappedName string
+	Typ        reflect.Type
+	Kind       reflect.Kind
 
 	//If the field is a pointer, fields below represent the underlying type,
 	// these fields are here to prevent reflect.PtrTo, or reflect.elem calls when setting primatives and basic types
 	IsPtr    bool
 	ElemTyp  reflect.Type // if Typ is *int, elemTyp is int
 	ElemKind reflect.Kind // if kind is ptr and typ is *int, elem kind is int
+
+	// Group is the length of a numbered column group, 0 if the field is not a group
+	// for example, `Phones [3]string `db:"phone#"`` maps columns phone1, phone2 and phone3
+	Group int
+
+	// Converter is the name of the converter selected with the conv db tag option, ie `db:"price,conv=cents"`
+	Converter string
+
+	setter *cellSetter // loads cells into the field, or into an element of a numbered column group
 }
 
 type Mapper struct {
@@ -43,6 +74,11 @@ type Mapper struct {
 
 	IsListPtr bool // true if destination is *[], false if destination is [], used only if cardinality is a collection
 
+	// IsArray is true if the collection is a fixed size array ([N]T or *[N]T) rather than a slice
+	IsArray  bool
+	ArrayLen int
+	Overflow OverflowPolicy // used only if IsArray, set with `carta:"name,overflow=truncate"`
+
 	// Basic mapper is used for collections where underlying type is basic (any field that is able to be set, look at isBasicType for more deatils )
 	// for example
 	// type User struct {
@@ -85,64 +121,182 @@ type Mapper struct {
 	// Nested structs which correspond to any has-one has-many relationships
 	// int is the ith element of this struct where the submap exists
 	SubMaps map[fieldIndex]*Mapper
+
+	setter *cellSetter // loads cells into the elements of a basic mapper
+
+	isAfterMapper bool // a pointer to Typ implements AfterMapper
 }
 
 // Maps db rows onto the complex struct,
 // Response must be a struct, pointer to a struct for our response, a slice of structs or slice of pointers to a struct
-func Map(rows *sql.Rows, dst interface{}) error {
+//
+// Errors caused by the shape of the result set or the values in it are *MappingError, which can be tested with
+// errors.Is against the sentinel errors, ie ErrNullToNonNullable. Mapping onto a struct returns ErrNoRows if
+// the query returned no rows.
+//
+// Rows of a slice of structs without has-one or has-many relationships are appended to dst as they are read,
+// duplicate rows are skipped unless WithoutDeduplication is given.
+func Map(rows *sql.Rows, dst interface{}, opts ...MapOption) error {
+	return MapSource(FromRows(rows), dst, opts...)
+}
+
+// MapSource maps the rows of src onto dst as Map does, and closes src
+func MapSource(src RowSource, dst interface{}, opts ...MapOption) error {
+	defer src.Close()
+	config := mapConfig{dedup: true}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return mapRows(src, dst, config)
+}
+
+// MapSets maps every result set of rows onto the next destination, for stored procedures and batches which return
+// more than one result set. Each result set is mapped as Map would, ie
+//
+//	err := carta.MapSets(rows, &blogs, &authors, &tags)
+//
+// A query which returns fewer result sets than there are destinations fails with ErrColumnMismatch,
+// errors name the result set they occurred in
+func MapSets(rows *sql.Rows, dsts ...interface{}) error {
+	return MapSourceSets(FromRows(rows), dsts...)
+}
+
+// MapSourceSets maps every result set of src onto the next destination as MapSets does, and closes src
+func MapSourceSets(src MultiRowSource, dsts ...interface{}) error {
+	defer src.Close()
+	for i, dst := range dsts {
+		if i > 0 && !src.NextResultSet() {
+			if err := src.Err(); err != nil {
+				return err
+			}
+			return newMappingError(KindColumnMismatch, reflect.TypeOf(dst), "query returned %d result sets, expected %d", i, len(dsts))
+		}
+		if err := mapRows(src, dst, mapConfig{dedup: true}); err != nil {
+			return fmt.Errorf("result set %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// mapRows maps the current result set of src onto dst, it leaves src open
+func mapRows(src RowSource, dst interface{}, config mapConfig) error {
 	var (
 		mapper *Mapper
 		err    error
 		rsv    *resolver
 	)
-	columns, err := rows.Columns()
+	columns, err := src.Columns()
 	if err != nil {
 		return err
 	}
-	columnTypes, err := rows.ColumnTypes()
+	databaseTypeNames, err := src.ColumnTypeNames()
 	if err != nil {
 		return err
 	}
 	dstTyp := reflect.TypeOf(dst)
-	mapper, ok := mapperCache.loadMap(columns, dstTyp)
+	key := newCacheKey(columns, dstTyp)
+	key.namer = config.namer
+	mapper, ok := mapperCache.loadMap(key)
 	if !ok {
-		if !(isSlicePtr(dstTyp) || isStructPtr(dstTyp)) {
-			return fmt.Errorf("carta: cannot map rows onto %s, destination must be pointer to a slice(*[]) or pointer to a struct", dstTyp)
-		}
-
-		// generate new mapper
-		if mapper, err = newMapper(dstTyp); err != nil {
+		if mapper, _, err = buildMapper(dstTyp, columns, config.namer); err != nil {
 			return err
 		}
+		mapperCache.storeMap(key, mapper)
+	}
 
-		// determine field names
-		if err = determineFieldsNames(mapper); err != nil {
-			return err
-		}
+	if mapper.isFlat() {
+		return mapper.loadFlatRows(src, databaseTypeNames, reflect.ValueOf(dst).Elem(), config.dedup)
+	}
+	if rsv, err = mapper.loadRows(src, databaseTypeNames, config.keepLast); err != nil {
+		return err
+	}
+	if mapper.Crd == Association && len(rsv.elementOrder) == 0 {
+		return ErrNoRows
+	}
 
-		// Allocate columns
-		columnsByName := map[string]column{}
-		for i, columnName := range columns {
-			columnsByName[columnName] = column{
-				name:        columnName,
-				typ:         columnTypes[i],
-				columnIndex: i,
-			}
-		}
-		if err = allocateColumns(mapper, columnsByName); err != nil {
-			return err
-		}
+	return setDst(mapper, reflect.ValueOf(dst), rsv)
+}
 
-		mapperCache.storeMap(columns, dstTyp, mapper)
+// MapOption changes how Map loads rows
+type MapOption func(*mapConfig)
 
+type mapConfig struct {
+	dedup    bool
+	keepLast bool
+	namer    FieldNamer
+}
+
+// WithoutDeduplication keeps every row of a flat result, rather than skipping rows whose columns are all equal to those
+// of a previous row. Without de-duplication, columns whose ColumnType.ScanType is the type of their field, and which are
+// either not nullable or loaded into a sql.NullXXX field, are scanned straight into the destination.
+//
+// It has no effect on destinations with has-one or has-many relationships, whose rows must be merged by identity.
+func WithoutDeduplication() MapOption {
+	return func(c *mapConfig) {
+		c.dedup = false
 	}
+}
 
-	if rsv, err = mapper.loadRows(rows, columnTypes); err != nil {
-		return err
+// KeepLastAssociation allows has-one relationships and struct destinations to receive more than one distinct entity,
+// of which the last one is kept. By default Map returns an error matching ErrCardinality, as more than one entity
+// usually comes from a missing join condition or a has-many relationship declared as has-one.
+func KeepLastAssociation() MapOption {
+	return func(c *mapConfig) {
+		c.keepLast = true
 	}
+}
 
-	return setDst(mapper, reflect.ValueOf(dst), rsv)
+// FieldNamer names the columns of struct fields, in addition to the names carta derives from their tag and Go name.
+// FieldName returns the column name of the ith field of the struct t, or "" if it has none.
+// Mappers are cached by namer, which must be comparable, ie a pointer or a struct of pointers
+type FieldNamer interface {
+	FieldName(t reflect.Type, i int) string
+}
+
+// WithFieldNamer matches the basic fields of the destination by the names namer gives them, as well as by carta's own
+// names. It is used by Mapx to honour the NameMapper and tag key of the sqlx mapper, see SqlxFieldNamer
+func WithFieldNamer(namer FieldNamer) MapOption {
+	return func(c *mapConfig) {
+		c.namer = namer
+	}
+}
+
+// buildMapper generates the mapper of dstTyp and allocates the columns of the result set to it.
+// namer may be nil. Columns which were not allocated to any field are returned as unclaimed.
+func buildMapper(dstTyp reflect.Type, columns []string, namer FieldNamer) (mapper *Mapper, unclaimed map[string]column, err error) {
+	if !(isSlicePtr(dstTyp) || isArrayPtr(dstTyp) || isStructPtr(dstTyp)) {
+		return nil, nil, newMappingError(KindInvalidDestination, dstTyp, "cannot map rows onto %s, destination must be pointer to a slice(*[]), pointer to an array(*[N]) or pointer to a struct", dstTyp)
+	}
+	if isArrayPtr(dstTyp) && isBasicType(dstTyp.Elem()) {
+		// ie a [16]byte UUID, which is loaded from a single column rather than being a collection of its elements
+		return nil, nil, newMappingError(KindInvalidDestination, dstTyp, "cannot map rows onto %s, %v is a basic type, map onto a slice of it", dstTyp, dstTyp.Elem())
+	}
+
+	// generate new mapper
+	if mapper, err = newMapper(dstTyp); err != nil {
+		return nil, nil, err
+	}
+
+	// determine field names
+	if err = determineFieldsNames(mapper); err != nil {
+		return nil, nil, err
+	}
+	if namer != nil {
+		mapFieldNames(mapper, namer)
+	}
 
+	// Allocate columns
+	columnsByName := map[string]column{}
+	for i, columnName := range columns {
+		columnsByName[columnName] = column{
+			name:        columnName,
+			columnIndex: i,
+		}
+	}
+	if err = allocateColumns(mapper, columnsByName); err != nil {
+		return nil, nil, err
+	}
+	return mapper, columnsByName, nil
 }
 
 func newMapper(t reflect.Type) (*Mapper, error) {
@@ -157,15 +311,25 @@ func newMapper(t reflect.Type) (*Mapper, error) {
 	isListPtr := false
 	isBasic := false
 	isTypePtr := false
+	isArray := false
+	arrayLen := 0
 
-	if isSlicePtr(t) {
+	if isSlicePtr(t) || isArrayPtr(t) {
 		crd = Collection
 		elemTyp = t.Elem().Elem() // *[]interface{} to intetrface{}
 		isListPtr = true
-	} else if t.Kind() == reflect.Slice {
+		if t.Elem().Kind() == reflect.Array {
+			isArray = true
+			arrayLen = t.Elem().Len()
+		}
+	} else if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
 		crd = Association
 		crd = Collection
 		elemTyp = t.Elem() // []interface{} to intetrface{}
+		if t.Kind() == reflect.Array {
+			isArray = true
+			arrayLen = t.Len()
+		}
 	}
 
 	if crd == Collection {
@@ -186,22 +350,34 @@ func newMapper(t reflect.Type) (*Mapper, error) {
 	}
 
 	if crd == Unknown {
-		return nil, errors.New("carts: unknown mapping")
+		return nil, newMappingError(KindInvalidDestination, t, "unknown mapping for %s", t)
 	}
 
 	mapper = &Mapper{
 		Crd:       crd,
 		IsListPtr: isListPtr,
+		IsArray:   isArray,
+		ArrayLen:  arrayLen,
 		IsBasic:   isBasic,
 		Typ:       elemTyp,
 		Kind:      elemTyp.Kind(),
 		IsTypePtr: isTypePtr,
 		Delimiter: "_",
 	}
+	if isBasic {
+		// basic elements are loaded directly, pointers are taken when the collection is set
+		if isTypePtr {
+			mapper.setter = compileSetter(reflect.PtrTo(elemTyp))
+		} else {
+			mapper.setter = compileSetter(elemTyp)
+		}
+		return mapper, nil
+	}
 	if subMaps, err = findSubMaps(mapper.Typ); err != nil {
 		return nil, err
 	}
 	mapper.SubMaps = subMaps
+	mapper.isAfterMapper = implementsAfterMapper(mapper.Typ)
 	return mapper, nil
 }
 
@@ -216,7 +392,7 @@ func findSubMaps(t reflect.Type) (map[fieldIndex]*Mapper, error) {
 	}
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if isExported(field) && isSubMap(field.Type) {
+		if isExported(field) && isSubMap(field.Type) && !isNumberedGroup(field) && converterName(field) == "" {
 			if subMap, err = newMapper(field.Type); err != nil {
 				return nil, err
 			}
@@ -226,6 +402,19 @@ func findSubMaps(t reflect.Type) (map[fieldIndex]*Mapper, error) {
 	return subMaps, nil
 }
 
+// mapFieldNames sets the MappedName of the fields of m and its sub maps
+func mapFieldNames(m *Mapper, namer FieldNamer) {
+	for i, field := range m.Fields {
+		if name := namer.FieldName(m.Typ, int(i)); name != field.Name {
+			field.MappedName = name
+			m.Fields[i] = field
+		}
+	}
+	for _, subMap := range m.SubMaps {
+		mapFieldNames(subMap, namer)
+	}
+}
+
 func determineFieldsNames(m *Mapper) error {
 	var (
 		name string
@@ -248,8 +437,21 @@ func determineFieldsNames(m *Mapper) error {
 					if len(parts) > 1 {
 						for _, part := range parts[1:] {
 							option := strings.Split(part, "=")
-							if len(option) == 2 && option[0] == "delimiter" {
+							if len(option) != 2 {
+								continue
+							}
+							switch option[0] {
+							case "delimiter":
 								subMap.Delimiter = option[1]
+							case "overflow":
+								switch option[1] {
+								case "error":
+									subMap.Overflow = OverflowError
+								case "truncate":
+									subMap.Overflow = OverflowTruncate
+								default:
+									return newMappingError(KindInvalidDestination, field.Type, "unknown overflow policy %q for field %s", option[1], field.Name)
+								}
 							}
 						}
 					}
@@ -258,20 +460,25 @@ func determineFieldsNames(m *Mapper) error {
 				}
 			} else {
 				if tag := nameFromTag(field.Tag, DbTagKey); tag != "" {
-					name = strings.TrimSpace(tag)
+					name = strings.TrimSpace(strings.Split(tag, ",")[0])
 				} else {
 					name = field.Name
 				}
 			}
-			f := Field{
-				Name:  name,
-				Typ:   field.Type,
-				Kind:  field.Type.Kind(),
-				IsPtr: (field.Type.Kind() == reflect.Ptr),
+			f := newField(name, field.Type)
+			f.Converter = converterName(field)
+			var err error
+			if isNumberedGroup(field) {
+				if f.Converter == "" && !isBasicType(field.Type.Elem()) {
+					return newMappingError(KindInvalidDestination, field.Type, "numbered column group %s must be an array of basic types, got %v", field.Name, field.Type)
+				}
+				f.Group = field.Type.Len()
+				f.setter, err = compileFieldSetter(field.Type.Elem(), field.Name, f.Converter)
+			} else if f.isBasic() {
+				f.setter, err = compileFieldSetter(field.Type, field.Name, f.Converter)
 			}
-			if f.IsPtr {
-				f.ElemKind = field.Type.Elem().Kind()
-				f.ElemTyp = field.Type.Elem()
+			if err != nil {
+				return err
 			}
 			fields[fieldIndex(i)] = f
 		}
@@ -285,6 +492,36 @@ func determineFieldsNames(m *Mapper) error {
 	return nil
 }
 
+// isBasic reports whether f is loaded from a single column, ie it is of a basic type or selects a converter
+func (f Field) isBasic() bool {
+	return f.Group == 0 && (f.Converter != "" || isBasicType(f.Typ))
+}
+
+func newField(name string, typ reflect.Type) Field {
+	f := Field{
+		Name:  name,
+		Typ:   typ,
+		Kind:  typ.Kind(),
+		IsPtr: (typ.Kind() == reflect.Ptr),
+	}
+	if f.IsPtr {
+		f.ElemKind = typ.Elem().Kind()
+		f.ElemTyp = typ.Elem()
+	}
+	return f
+}
+
+// groupColumnName returns the column name of the nth (zero based) element of a numbered column group,
+// "phone#" becomes "phone1" for n = 0
+func groupColumnName(name string, n int) string {
+	return strings.Replace(name, "#", strconv.Itoa(n+1), 1)
+}
+
+// numbered column groups are arrays of basic types which use # in the db tag as a placeholder for the column number
+func isNumberedGroup(f reflect.StructField) bool {
+	return f.Type.Kind() == reflect.Array && strings.Contains(nameFromTag(f.Tag, DbTagKey), "#")
+}
+
 func isExported(f reflect.StructField) bool {
 	return (f.PkgPath == "")
 }
@@ -297,11 +534,12 @@ func isSubMap(t reflect.Type) bool {
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
-	return (!isBasicType(t) && (t.Kind() == reflect.Struct || t.Kind() == reflect.Slice))
+	return (!isBasicType(t) && (t.Kind() == reflect.Struct || t.Kind() == reflect.Slice || t.Kind() == reflect.Array))
 }
 
 // Basic types are any types that are intended to be set from sql row data
-// Primative fields, sql.NullXXX, time.Time, proto timestamp qualify as basic
+// Primative fields, sql.NullXXX, time.Time, proto timestamp and UUIDs ([16]byte) qualify as basic,
+// as do types which implement sql.Scanner or encoding.TextUnmarshaler
 func isBasicType(t reflect.Type) bool {
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -312,7 +550,10 @@ func isBasicType(t reflect.Type) bool {
 	if _, ok := value.BasicTypes[t]; ok {
 		return true
 	}
-	return false
+	if value.IsUUIDType(t) {
+		return true
+	}
+	return isSettable(t)
 }
 
 // test wether the type to be set is a pointer to a struct, courtesy of BQ api
@@ -323,3 +564,7 @@ func isStructPtr(t reflect.Type) bool {
 func isSlicePtr(t reflect.Type) bool {
 	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice
 }
+
+func isArrayPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Array
+}
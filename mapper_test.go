@@ -1,8 +1,10 @@
 package carta
 
 import (
+	"database/sql/driver"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -701,3 +703,478 @@ func TestNestedBasicSliceMap_TagWhitespace(t *testing.T) {
 		t.Errorf("unfulfilled expectations: %s", err)
 	}
 }
+
+type UserWithPostArray struct {
+	ID    int     `db:"id"`
+	Posts [2]Post `carta:"posts"`
+}
+
+type UserWithTruncatedPostArray struct {
+	ID    int      `db:"id"`
+	Posts [2]*Post `carta:"posts,overflow=truncate"`
+}
+
+type ContactWithPhones struct {
+	ID     int        `db:"id"`
+	Phones [3]*string `db:"phone#"`
+}
+
+func TestArrayCollectionMap(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "posts_title", "posts_content"}).
+		AddRow(1, "First Post", "Hello World").
+		AddRow(1, "Second Post", "Another post")
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []UserWithPostArray
+	if err := Map(sqlRows, &users); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	expected := [2]Post{{Title: "First Post", Content: "Hello World"}, {Title: "Second Post", Content: "Another post"}}
+	if users[0].Posts != expected {
+		t.Errorf("expected posts to be %+v, but got %+v", expected, users[0].Posts)
+	}
+}
+
+func TestArrayCollectionMap_OverflowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "posts_title", "posts_content"}).
+		AddRow(1, "First Post", "a").
+		AddRow(1, "Second Post", "b").
+		AddRow(1, "Third Post", "c")
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []UserWithPostArray
+	err = Map(sqlRows, &users)
+	if err == nil {
+		t.Fatalf("expected an error when a has-many relationship overflows an array, got nil")
+	}
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) || mappingErr.Kind != KindCardinality {
+		t.Fatalf("expected a cardinality MappingError, got %v", err)
+	}
+	if mappingErr.FieldPath != "UserWithPostArray.Posts" || mappingErr.Column != "posts_title" {
+		t.Errorf("expected the error to name UserWithPostArray.Posts and column posts_title, got %q", err.Error())
+	}
+}
+
+func TestArrayCollectionMap_OverflowErrorNested(t *testing.T) {
+	type Label struct {
+		ID int `db:"id"`
+	}
+	type Post struct {
+		ID     int      `db:"id"`
+		Labels [1]Label `carta:"labels"`
+	}
+	type Blog struct {
+		ID    int    `db:"id"`
+		Posts []Post `carta:"posts"`
+	}
+	rows := queryRows(t, []string{"id", "posts_id", "posts_labels_id"},
+		[]driver.Value{1, 10, 100},
+		[]driver.Value{1, 20, 200},
+		[]driver.Value{1, 20, 201},
+	)
+	var blogs []Blog
+	err := Map(rows, &blogs)
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) || mappingErr.Kind != KindCardinality {
+		t.Fatalf("expected a cardinality MappingError, got %v", err)
+	}
+	if mappingErr.FieldPath != "Blog.Posts[1].Labels" || mappingErr.Column != "posts_labels_id" {
+		t.Errorf("expected the error to name Blog.Posts[1].Labels and column posts_labels_id, got %q", err.Error())
+	}
+}
+
+func TestArrayCollectionMap_OverflowTruncate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "posts_title", "posts_content"}).
+		AddRow(1, "First Post", "a").
+		AddRow(1, "Second Post", "b").
+		AddRow(1, "Third Post", "c")
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []UserWithTruncatedPostArray
+	if err := Map(sqlRows, &users); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	if users[0].Posts[0].Title != "First Post" || users[0].Posts[1].Title != "Second Post" {
+		t.Errorf("expected the first two posts to be kept, got %+v, %+v", users[0].Posts[0], users[0].Posts[1])
+	}
+}
+
+func TestMapToArray(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"ID", "Name"}).
+		AddRow(1, "John Doe").
+		AddRow(2, "Jane Doe")
+
+	mock.ExpectQuery("SELECT (.+) FROM users").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users [3]User
+	if err := Map(sqlRows, &users); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [3]User{{ID: 1, Name: "John Doe"}, {ID: 2, Name: "Jane Doe"}}
+	if users != expected {
+		t.Errorf("expected users to be %+v, but got %+v", expected, users)
+	}
+}
+
+func TestNumberedColumnGroupMap(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "phone1", "phone2", "phone3"}).
+		AddRow(1, "555-0100", nil, "555-0102")
+
+	mock.ExpectQuery("SELECT (.+) FROM contacts").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM contacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contacts []ContactWithPhones
+	if err := Map(sqlRows, &contacts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(contacts) != 1 {
+		t.Fatalf("expected 1 contact, got %d", len(contacts))
+	}
+	phones := contacts[0].Phones
+	if phones[0] == nil || *phones[0] != "555-0100" {
+		t.Errorf("expected phone1 to be 555-0100, got %v", phones[0])
+	}
+	if phones[1] != nil {
+		t.Errorf("expected phone2 to be nil, got %v", *phones[1])
+	}
+	if phones[2] == nil || *phones[2] != "555-0102" {
+		t.Errorf("expected phone3 to be 555-0102, got %v", phones[2])
+	}
+}
+
+func TestNumberedColumnGroup_NonBasicError(t *testing.T) {
+	type Contact struct {
+		ID        int        `db:"id"`
+		Addresses [2]Address `db:"address#"`
+	}
+	m, err := newMapper(reflect.TypeOf(&[]Contact{}))
+	if err != nil {
+		t.Fatalf("error creating new mapper: %s", err)
+	}
+	if err := determineFieldsNames(m); err == nil {
+		t.Errorf("expected an error for a numbered column group of structs, got nil")
+	}
+}
+
+func TestMapErrorFieldPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "posts_id", "posts_title", "posts_labels_id", "posts_labels_name"}).
+		AddRow(1, "Blog 1", 101, "Post 1", nil, nil).
+		AddRow(1, "Blog 1", 102, nil, nil, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM blogs").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM blogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blogs []BlogWithPosts
+	err = Map(sqlRows, &blogs)
+	if err == nil {
+		t.Fatalf("expected an error when loading NULL into a string, got nil")
+	}
+	expected := "carta: row 2: BlogWithPosts.Posts[1].Title (column posts_title): cannot load NULL into string"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestMapErrorFieldPath_Conversion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "title", "author_id", "author_name"}).
+		AddRow(1, "My First Blog", "not a number", "John Doe")
+
+	mock.ExpectQuery("SELECT (.+) FROM blogs").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM blogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blogs []Blog
+	err = Map(sqlRows, &blogs)
+	if err == nil {
+		t.Fatalf("expected a conversion error, got nil")
+	}
+	expectedPrefix := "carta: row 1: Blog.Author.ID (column author_id): cannot convert to int: "
+	if !strings.HasPrefix(err.Error(), expectedPrefix) {
+		t.Errorf("expected error to start with %q, got %q", expectedPrefix, err.Error())
+	}
+}
+
+func TestMapCompositeIdentity(t *testing.T) {
+	type Post struct {
+		Blog string `db:"blog"`
+		Slug string `db:"slug"`
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// "1"+"23" and "12"+"3" used to produce the same identity and were merged into one post
+	rows := sqlmock.NewRows([]string{"blog", "slug"}).
+		AddRow("1", "23").
+		AddRow("12", "3")
+	mock.ExpectQuery("SELECT (.+) FROM posts").WillReturnRows(rows)
+
+	sqlRows, err := db.Query("SELECT * FROM posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var posts []Post
+	if err := Map(sqlRows, &posts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Post{{Blog: "1", Slug: "23"}, {Blog: "12", Slug: "3"}}
+	if !reflect.DeepEqual(posts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, posts)
+	}
+}
+
+func TestMapAssociationCardinality(t *testing.T) {
+	columns := []string{"id", "title", "author_id", "author_name"}
+	tests := []struct {
+		name     string
+		rows     [][]driver.Value
+		dst      func() interface{}
+		opts     []MapOption
+		path     string
+		row      int
+		expected interface{}
+	}{
+		{
+			name: "has-one relationship",
+			rows: [][]driver.Value{{1, "Blog 1", 10, "Ann"}, {1, "Blog 1", 11, "Bob"}},
+			dst:  func() interface{} { return &[]Blog{} },
+			path: "Blog.Author",
+			row:  2,
+		},
+		{
+			name: "struct destination",
+			rows: [][]driver.Value{{1, "Blog 1", 10, "Ann"}, {2, "Blog 2", 10, "Ann"}},
+			dst:  func() interface{} { return &Blog{} },
+			path: "Blog",
+			row:  2,
+		},
+		{
+			name:     "repeated entity",
+			rows:     [][]driver.Value{{1, "Blog 1", 10, "Ann"}, {1, "Blog 1", 10, "Ann"}, {2, "Blog 2", 11, "Bob"}},
+			dst:      func() interface{} { return &[]Blog{} },
+			expected: &[]Blog{{ID: 1, Title: "Blog 1", Author: Author{ID: 10, Name: "Ann"}}, {ID: 2, Title: "Blog 2", Author: Author{ID: 11, Name: "Bob"}}},
+		},
+		{
+			name:     "keep last has-one relationship",
+			rows:     [][]driver.Value{{1, "Blog 1", 10, "Ann"}, {1, "Blog 1", 11, "Bob"}},
+			dst:      func() interface{} { return &[]Blog{} },
+			opts:     []MapOption{KeepLastAssociation()},
+			expected: &[]Blog{{ID: 1, Title: "Blog 1", Author: Author{ID: 11, Name: "Bob"}}},
+		},
+		{
+			name:     "keep last struct destination",
+			rows:     [][]driver.Value{{1, "Blog 1", 10, "Ann"}, {2, "Blog 2", 10, "Ann"}},
+			dst:      func() interface{} { return &Blog{} },
+			opts:     []MapOption{KeepLastAssociation()},
+			expected: &Blog{ID: 2, Title: "Blog 2", Author: Author{ID: 10, Name: "Ann"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows(columns)
+			for _, row := range tt.rows {
+				rows.AddRow(row...)
+			}
+			mock.ExpectQuery("SELECT (.+) FROM blogs").WillReturnRows(rows)
+			sqlRows, err := db.Query("SELECT * FROM blogs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dst := tt.dst()
+			err = Map(sqlRows, dst, tt.opts...)
+			if tt.expected != nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(dst, tt.expected) {
+					t.Errorf("expected %+v, got %+v", tt.expected, dst)
+				}
+				return
+			}
+			if !errors.Is(err, ErrCardinality) {
+				t.Fatalf("expected ErrCardinality, got %v", err)
+			}
+			var mErr *MappingError
+			if !errors.As(err, &mErr) || mErr.FieldPath != tt.path || mErr.Row != tt.row {
+				t.Errorf("expected an error at row %d of %s, got %v", tt.row, tt.path, err)
+			}
+		})
+	}
+}
+
+func TestMapSets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	blogRows := sqlmock.NewRows([]string{"id", "title", "author_id", "author_name"}).
+		AddRow(1, "first", 10, "ann").
+		AddRow(2, "second", 11, "bob")
+	userRows := sqlmock.NewRows([]string{"ID", "Name"}).
+		AddRow(10, "ann")
+	tagRows := sqlmock.NewRows([]string{"tag"}).
+		AddRow("go").
+		AddRow("sql")
+	mock.ExpectQuery("EXEC dashboard").WillReturnRows(blogRows, userRows, tagRows)
+
+	sqlRows, err := db.Query("EXEC dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		blogs []Blog
+		user  User
+		tags  []string
+	)
+	if err := MapSets(sqlRows, &blogs, &user, &tags); err != nil {
+		t.Fatal(err)
+	}
+	expectedBlogs := []Blog{
+		{ID: 1, Title: "first", Author: Author{ID: 10, Name: "ann"}},
+		{ID: 2, Title: "second", Author: Author{ID: 11, Name: "bob"}},
+	}
+	if !reflect.DeepEqual(blogs, expectedBlogs) {
+		t.Errorf("expected blogs %+v, got %+v", expectedBlogs, blogs)
+	}
+	if user != (User{ID: 10, Name: "ann"}) {
+		t.Errorf("expected user {ID:10 Name:ann}, got %+v", user)
+	}
+	if !reflect.DeepEqual(tags, []string{"go", "sql"}) {
+		t.Errorf("expected tags [go sql], got %v", tags)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMapSetsErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("EXEC dashboard").WillReturnRows(
+		sqlmock.NewRows([]string{"ID", "Name"}).AddRow(10, "ann"),
+		sqlmock.NewRows([]string{"ID", "Name"}).AddRow("x", "bob"),
+	)
+	mock.ExpectQuery("EXEC dashboard").WillReturnRows(
+		sqlmock.NewRows([]string{"ID", "Name"}).AddRow(10, "ann"),
+	)
+
+	// the error names the result set it occurred in
+	sqlRows, err := db.Query("EXEC dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first, second []User
+	err = MapSets(sqlRows, &first, &second)
+	if !errors.Is(err, ErrConversion) || !strings.HasPrefix(err.Error(), "result set 2: ") {
+		t.Errorf("expected a conversion error in result set 2, got %v", err)
+	}
+
+	// fewer result sets than destinations
+	sqlRows, err = db.Query("EXEC dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = MapSets(sqlRows, &first, &second)
+	if !errors.Is(err, ErrColumnMismatch) {
+		t.Errorf("expected a column mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package carta
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+)
+
+// Queryer runs a query, it is satisfied by *sql.DB, *sql.Tx, *sql.Conn, *sqlx.DB and *sqlx.Tx
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Query runs query with q and maps its rows onto dst, as Map does.
+// MapOption values among args configure the mapping rather than being passed to the query
+//
+//	var blogs []Blog
+//	err := carta.Query(ctx, db, &blogs, "SELECT ... FROM blog b JOIN author a ON ...", userID, carta.WithoutDeduplication())
+func Query(ctx context.Context, q Queryer, dst interface{}, query string, args ...interface{}) error {
+	args, opts := splitMapOptions(args)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return Map(rows, dst, opts...)
+}
+
+// Get runs query with q and maps exactly one top level entity onto dst, a pointer to a struct or a basic type.
+// Rows are merged into has-one and has-many relationships as Map does, so the query may return any number of rows
+// for that entity. Get returns ErrNoRows if the query returned no rows, and an error matching ErrCardinality if
+// the rows hold more than one entity. MapOption values among args configure the mapping, as they do for Query.
+func Get(ctx context.Context, q Queryer, dst interface{}, query string, args ...interface{}) error {
+	dstTyp := reflect.TypeOf(dst)
+	if dstTyp == nil || dstTyp.Kind() != reflect.Ptr || isCollection(dstTyp.Elem()) {
+		return newMappingError(KindInvalidDestination, dstTyp, "cannot get a single entity into %v, destination must be a pointer to a struct or basic type", dstTyp)
+	}
+	args, opts := splitMapOptions(args)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	// entities are mapped onto a slice, so that more than one entity is detected rather than merged into dst
+	list := reflect.New(reflect.SliceOf(dstTyp.Elem()))
+	if err := Map(rows, list.Interface(), opts...); err != nil {
+		return err
+	}
+	switch n := list.Elem().Len(); {
+	case n == 0:
+		return ErrNoRows
+	case n > 1:
+		return newMappingError(KindCardinality, dstTyp.Elem(), "query returned %d entities of %v, expected one", n, dstTyp.Elem())
+	}
+	reflect.ValueOf(dst).Elem().Set(list.Elem().Index(0))
+	return nil
+}
+
+// isCollection reports whether typ is a slice or array of entities, arrays such as UUIDs which are basic types are single values
+func isCollection(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array && !isBasicType(typ)
+}
+
+// splitMapOptions separates the MapOption values of args, which configure the mapping, from the query arguments.
+// A MapOption is never a valid query argument, so Query, Get and Selectx accept both after the query.
+func splitMapOptions(args []interface{}) ([]interface{}, []MapOption) {
+	var queryArgs []interface{}
+	var opts []MapOption
+	for _, arg := range args {
+		if opt, ok := arg.(MapOption); ok {
+			opts = append(opts, opt)
+		} else {
+			queryArgs = append(queryArgs, arg)
+		}
+	}
+	return queryArgs, opts
+}
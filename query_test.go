@@ -0,0 +1,158 @@
+package carta
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+var (
+	_ Queryer = (*sql.DB)(nil)
+	_ Queryer = (*sql.Tx)(nil)
+	_ Queryer = (*sql.Conn)(nil)
+	_ Queryer = (*sqlx.DB)(nil)
+	_ Queryer = (*sqlx.Tx)(nil)
+)
+
+func TestQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM blogs WHERE author_id = ?").WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "author_id", "author_name"}).
+			AddRow(1, "first", 10, "ann").
+			AddRow(2, "second", 10, "ann"))
+
+	var blogs []Blog
+	if err := Query(context.Background(), db, &blogs, "SELECT * FROM blogs WHERE author_id = ?", 10); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Blog{
+		{ID: 1, Title: "first", Author: Author{ID: 10, Name: "ann"}},
+		{ID: 2, Title: "second", Author: Author{ID: 10, Name: "ann"}},
+	}
+	if !reflect.DeepEqual(blogs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, blogs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// the options are not passed to the query, which would fail the expected arguments
+	mock.ExpectQuery("SELECT (.+) FROM authors WHERE id = ?").WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(10, "ann").
+			AddRow(10, "ann"))
+
+	var authors []Author
+	if err := Query(context.Background(), db, &authors, "SELECT * FROM authors WHERE id = ?", 10, WithoutDeduplication()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Author{{ID: 10, Name: "ann"}, {ID: 10, Name: "ann"}}
+	if !reflect.DeepEqual(authors, expected) {
+		t.Errorf("expected %+v, got %+v", expected, authors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	queryErr := errors.New("connection reset")
+	mock.ExpectQuery("SELECT").WillReturnError(queryErr)
+
+	var blogs []Blog
+	if err := Query(context.Background(), db, &blogs, "SELECT"); !errors.Is(err, queryErr) {
+		t.Errorf("expected the query error, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	columns := []string{"ID", "Name", "Posts_Title", "Posts_Content"}
+
+	// the rows of a single entity are merged
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, "ann", "first", "hello").
+		AddRow(1, "ann", "second", "again"))
+	var user UserWithPosts
+	if err := Get(context.Background(), db, &user, "SELECT"); err != nil {
+		t.Fatal(err)
+	}
+	expected := UserWithPosts{ID: 1, Name: "ann", Posts: []Post{{Title: "first", Content: "hello"}, {Title: "second", Content: "again"}}}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("expected %+v, got %+v", expected, user)
+	}
+
+	// no rows
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns))
+	if err := Get(context.Background(), db, &user, "SELECT"); !errors.Is(err, ErrNoRows) {
+		t.Errorf("expected ErrNoRows, got %v", err)
+	}
+
+	// more than one entity
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, "ann", "first", "hello").
+		AddRow(2, "bob", "second", "again"))
+	user = UserWithPosts{}
+	if err := Get(context.Background(), db, &user, "SELECT"); !errors.Is(err, ErrCardinality) {
+		t.Errorf("expected ErrCardinality, got %v", err)
+	}
+	if !reflect.DeepEqual(user, UserWithPosts{}) {
+		t.Errorf("expected dst to be left alone, got %+v", user)
+	}
+
+	// basic types
+	mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	var count int
+	if err := Get(context.Background(), db, &count, "SELECT count(*) FROM users"); err != nil || count != 42 {
+		t.Errorf("expected 42, got %d, %v", count, err)
+	}
+
+	// UUIDs are basic types rather than arrays of entities
+	id := testUUID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	mock.ExpectQuery("SELECT id").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("12345678-9abc-def0-0123-456789abcdef"))
+	var uuid testUUID
+	if err := Get(context.Background(), db, &uuid, "SELECT id FROM users"); err != nil || uuid != id {
+		t.Errorf("expected %x, got %x, %v", id, uuid, err)
+	}
+
+	// options configure the mapping
+	mock.ExpectQuery("SELECT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"ID", "NAME"}).AddRow(1, "ann"))
+	var named User
+	namer := SqlxFieldNamer{Mapper: reflectx.NewMapperFunc("db", strings.ToUpper)}
+	if err := Get(context.Background(), db, &named, "SELECT", 1, WithFieldNamer(namer)); err != nil || named.Name != "ann" {
+		t.Errorf("expected ann, got %+v, %v", named, err)
+	}
+
+	// slices are not single entities
+	var users []User
+	if err := Get(context.Background(), db, &users, "SELECT"); !errors.Is(err, ErrInvalidDestination) {
+		t.Errorf("expected ErrInvalidDestination, got %v", err)
+	}
+}
@@ -23,7 +23,8 @@ import (
 // TODO: consider passing resover in context value
 
 type (
-	uniqueValId string
+	// uniqueValId is a 128 bit hash of the identity columns of an element, see getUniqueId
+	uniqueValId [2]uint64
 	fieldIndex  int
 )
 
@@ -0,0 +1,138 @@
+package carta
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ColumnsOptions configures the column expressions generated by Columns
+type ColumnsOptions struct {
+	// Aliases maps the Go path of the destination and each of its has-one/has-many fields to the table alias
+	// their columns are selected from, ie {"": "b", "Posts": "p", "Posts.Author": "a"}.
+	// Fields without an alias use the alias of their nearest ancestor.
+	Aliases map[string]string
+
+	// Quote quotes column aliases which are not plain identifiers, ie "posts->author->name".
+	// By default aliases are quoted with double quotes, use backticks for MySQL.
+	Quote func(string) string
+}
+
+var plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Columns generates the column expressions of a SELECT statement which maps onto a T,
+// where T is the type which would be passed to Map as &dst, ie Columns[[]Blog](opts).
+// Nested fields are aliased using the same names and delimiters carta matches when mapping:
+//
+//	cols, err := carta.Columns[[]Blog](carta.ColumnsOptions{
+//		Aliases: map[string]string{"": "b", "Posts": "p", "Posts.Author": "a"},
+//	})
+//	// b.id, b.title, p.id AS "posts->id", a.name AS "posts->author->name"
+//	query := "SELECT " + strings.Join(cols, ", ") + " FROM blog b ..."
+//
+// Fields without a db tag are selected by the snake_case version of their name.
+func Columns[T any](opts ColumnsOptions) ([]string, error) {
+	var (
+		m   *Mapper
+		err error
+	)
+	dstTyp := reflect.TypeOf((*T)(nil))
+	if m, err = newMapper(dstTyp); err != nil {
+		return nil, err
+	}
+	if err = determineFieldsNames(m); err != nil {
+		return nil, err
+	}
+	if opts.Quote == nil {
+		opts.Quote = quoteIdentifier
+	}
+	used := map[string]bool{}
+	exprs := selectColumns(m, []string{}, "", opts.Aliases[""], opts, used, nil)
+	for path := range opts.Aliases {
+		if path != "" && !used[path] {
+			return nil, newMappingError(KindInvalidDestination, dstTyp, "table alias given for %s, which is not a has-one or has-many field of %s", path, typeName(m.Typ))
+		}
+	}
+	return exprs, nil
+}
+
+func selectColumns(m *Mapper, ancestorNames []string, path string, alias string, opts ColumnsOptions, used map[string]bool, exprs []string) []string {
+	if m.IsBasic {
+		if len(ancestorNames) == 0 {
+			return exprs
+		}
+		source := toSnakeCase(ancestorNames[len(ancestorNames)-1])
+		return append(exprs, selectExpr(alias, source, columnAlias("", ancestorNames, m.Delimiter), opts))
+	}
+
+	for n := 0; n < m.Typ.NumField(); n++ {
+		i := fieldIndex(n)
+		field, ok := m.Fields[i]
+		if !ok {
+			continue
+		}
+		goField := m.Typ.Field(n)
+		if subMap, isSubMap := m.SubMaps[i]; isSubMap {
+			subPath := goField.Name
+			if path != "" {
+				subPath = path + "." + goField.Name
+			}
+			subAlias := alias
+			if a, ok := opts.Aliases[subPath]; ok {
+				subAlias = a
+				used[subPath] = true
+			}
+			subAncestors := append(ancestorNames[:len(ancestorNames):len(ancestorNames)], field.Name)
+			exprs = selectColumns(subMap, subAncestors, subPath, subAlias, opts, used, exprs)
+			continue
+		}
+
+		source := field.Name
+		if nameFromTag(goField.Tag, DbTagKey) == "" {
+			source = toSnakeCase(field.Name)
+		}
+		if field.isBasic() {
+			exprs = append(exprs, selectExpr(alias, source, columnAlias(source, ancestorNames, m.Delimiter), opts))
+		} else if field.Group > 0 {
+			for e := 0; e < field.Group; e++ {
+				name := groupColumnName(source, e)
+				exprs = append(exprs, selectExpr(alias, name, columnAlias(name, ancestorNames, m.Delimiter), opts))
+			}
+		}
+	}
+	return exprs
+}
+
+// columnAlias is the column name carta expects for a field, when the delimiter is "_" the snake case
+// name is used, ie posts_author_name, otherwise the names are joined by the delimiter, ie posts->author->name
+func columnAlias(name string, ancestorNames []string, delimiter string) string {
+	parts := make([]string, 0, len(ancestorNames)+1)
+	parts = append(parts, ancestorNames...)
+	if name != "" {
+		parts = append(parts, name)
+	}
+	if delimiter == "_" {
+		for i, part := range parts {
+			parts[i] = toSnakeCase(part)
+		}
+	}
+	return strings.Join(parts, delimiter)
+}
+
+func selectExpr(tableAlias string, source string, columnAlias string, opts ColumnsOptions) string {
+	expr := source
+	if tableAlias != "" {
+		expr = tableAlias + "." + source
+	}
+	if columnAlias == source {
+		return expr
+	}
+	if !plainIdentifier.MatchString(columnAlias) {
+		columnAlias = opts.Quote(columnAlias)
+	}
+	return expr + " AS " + columnAlias
+}
@@ -0,0 +1,100 @@
+package carta
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumns(t *testing.T) {
+	cols, err := Columns[[]BlogWithPosts](ColumnsOptions{
+		Aliases: map[string]string{"": "b", "Posts": "p", "Posts.Labels": "l"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"b.id",
+		"b.name",
+		`p.id AS "posts->id"`,
+		`p.title AS "posts->title"`,
+		`l.id AS "posts->labels->id"`,
+		`l.name AS "posts->labels->name"`,
+	}
+	if !reflect.DeepEqual(cols, expected) {
+		t.Errorf("expected %v, got %v", expected, cols)
+	}
+}
+
+func TestColumnsMapBack(t *testing.T) {
+	testCases := []struct {
+		name     string
+		columns  func() ([]string, error)
+		validate func([]string) error
+	}{
+		{
+			name:     "Untagged fields",
+			columns:  func() ([]string, error) { return Columns[[]UserWithPosts](ColumnsOptions{}) },
+			validate: func(c []string) error { return Validate[[]UserWithPosts](c) },
+		},
+		{
+			name:     "Custom delimiter",
+			columns:  func() ([]string, error) { return Columns[[]BlogWithCustomDelimiter](ColumnsOptions{}) },
+			validate: func(c []string) error { return Validate[[]BlogWithCustomDelimiter](c) },
+		},
+		{
+			name:     "Numbered column group",
+			columns:  func() ([]string, error) { return Columns[[]ContactWithPhones](ColumnsOptions{}) },
+			validate: func(c []string) error { return Validate[[]ContactWithPhones](c) },
+		},
+		{
+			name:     "Nested basic slice",
+			columns:  func() ([]string, error) { return Columns[[]PostWithTags](ColumnsOptions{}) },
+			validate: func(c []string) error { return Validate[[]PostWithTags](c) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs, err := tc.columns()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the column names of the result set are the aliases, or the selected column when there is no alias
+			columns := make([]string, len(exprs))
+			for i, expr := range exprs {
+				name := expr
+				if j := strings.LastIndex(expr, " AS "); j >= 0 {
+					name = strings.Trim(expr[j+len(" AS "):], `"`)
+				}
+				columns[i] = name
+			}
+			if err := tc.validate(columns); err != nil {
+				t.Errorf("expected columns %v to map back, got %v", exprs, err)
+			}
+		})
+	}
+}
+
+func TestColumnsQuote(t *testing.T) {
+	cols, err := Columns[[]Blog](ColumnsOptions{
+		Quote: func(name string) string { return "`" + name + "`" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"id", "title", "id AS `author->id`", "name AS `author->name`"}
+	if !reflect.DeepEqual(cols, expected) {
+		t.Errorf("expected %v, got %v", expected, cols)
+	}
+}
+
+func TestColumnsUnknownAlias(t *testing.T) {
+	_, err := Columns[[]BlogWithPosts](ColumnsOptions{
+		Aliases: map[string]string{"Post": "p"},
+	})
+	if !errors.Is(err, ErrInvalidDestination) {
+		t.Errorf("expected ErrInvalidDestination for an alias of an unknown field, got %v", err)
+	}
+}
@@ -1,42 +1,62 @@
 package carta
 
 import (
-	"errors"
+	"fmt"
 	"reflect"
 )
 
+// emptyResolver stands in for the resolver of a sub map which no row held an element of, it must not be modified
+var emptyResolver = newResolver()
+
 func setDst(m *Mapper, dst reflect.Value, rsv *resolver) error {
+	return setDstAt(m, dst, rsv, nil)
+}
+
+// setDstAt sets the elements of rsv into dst, parent is the path of the element which holds dst, nil for the destination
+// of Map, its i is the index of the field of dst
+func setDstAt(m *Mapper, dst reflect.Value, rsv *resolver, parent *fieldPath) error {
 	// dst is  always a pointer
 	dstIndirect := reflect.Indirect(dst)
 
-	// post order traversal, first set all submap structs, then the struct itself
+	// the path of the element being set, it is only rendered if an error is reported, before the next element is set
+	path := &fieldPath{parent: parent, m: m, rsv: rsv}
+	if parent != nil {
+		path.owner = parent.m
+	}
+
+	// post order traversal, first set all submap structs, then the struct itself, of which AfterCartaMap is called
+	// before it is copied into dst
+	// sub maps are set in struct field order, so that the AfterCartaMap hooks of siblings are called in that order
+	subMapIndexes := m.sortedSubMapIndexes()
 	for _, uid := range rsv.elementOrder {
 		elem := rsv.elements[uid]
+		path.uid = uid
 
 		//set childeren first
-		for fieldIndex, subMapRsv := range elem.subMaps {
+		for _, fieldIndex := range subMapIndexes {
+			subMap := m.SubMaps[fieldIndex]
 			var (
-				subMap       *Mapper
 				childTyp     reflect.Type
 				childDst     reflect.Value
 				newChildElem reflect.Value
-				ok           bool
 			)
 
-			if subMap, ok = m.SubMaps[fieldIndex]; !ok {
-				// this should never happen
-				return errors.New("carta: sub map not found")
-			}
-			if f, ok := m.SubMaps[fieldIndex]; ok {
-				childTyp = f.Typ
-			} else {
-				// this should never happen
-				return errors.New("carta: field not found")
+			subMapRsv, ok := elem.subMaps[fieldIndex]
+			if !ok {
+				// no row held an element of this sub map
+				subMapRsv = emptyResolver
 			}
+			childTyp = subMap.Typ
 
 			if subMap.Crd == Collection {
 				capacity := len(subMapRsv.elements)
-				if subMap.IsTypePtr {
+				if subMap.IsArray {
+					elemTyp := childTyp
+					if subMap.IsTypePtr {
+						elemTyp = reflect.PtrTo(childTyp)
+					}
+					newChildElem = reflect.New(reflect.ArrayOf(subMap.ArrayLen, elemTyp)).Elem()
+				} else if subMap.IsTypePtr {
 					newChildElem = reflect.New(reflect.SliceOf(reflect.PtrTo(childTyp))).Elem()
 					newChildElem.Set(reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(childTyp)), 0, capacity))
 				} else {
@@ -66,18 +86,43 @@ func setDst(m *Mapper, dst reflect.Value, rsv *resolver) error {
 
 			// setting the child
 			if len(subMapRsv.elements) > 0 {
-				setDst(subMap, childDst, subMapRsv)
+				path.i = fieldIndex
+				if err := setDstAt(subMap, childDst, subMapRsv, path); err != nil {
+					return err
+				}
+			}
+		}
+		if m.isAfterMapper {
+			if err := m.afterMap(elem.v); err != nil {
+				return err
 			}
 		}
 	}
 
-	for _, uid := range rsv.elementOrder {
+	if m.IsArray && len(rsv.elementOrder) > m.ArrayLen && m.Overflow != OverflowTruncate {
+		return &MappingError{
+			Kind:      KindCardinality,
+			Column:    firstColumn(m),
+			FieldPath: collectionPath(m, parent),
+			GoType:    m.Typ,
+			Err:       fmt.Errorf("%d elements of %v do not fit into an array of length %d, use the overflow=truncate carta tag option to keep the first %d", len(rsv.elementOrder), m.Typ, m.ArrayLen, m.ArrayLen),
+		}
+	}
+
+	for n, uid := range rsv.elementOrder {
 		elem := rsv.elements[uid]
 		if m.Crd == Collection {
+			v := elem.v
 			if m.IsTypePtr {
-				dstIndirect.Set(reflect.Append(dstIndirect, elem.v.Addr()))
+				v = elem.v.Addr()
+			}
+			if m.IsArray {
+				if n >= m.ArrayLen {
+					break // truncated
+				}
+				dstIndirect.Index(n).Set(v)
 			} else {
-				dstIndirect.Set(reflect.Append(dstIndirect, elem.v))
+				dstIndirect.Set(reflect.Append(dstIndirect, v))
 			}
 		} else if m.Crd == Association {
 			dstIndirect.Set(elem.v)
@@ -85,3 +130,23 @@ func setDst(m *Mapper, dst reflect.Value, rsv *resolver) error {
 	}
 	return nil
 }
+
+// collectionPath is the Go path of the collection m is set into, ie Blog[0].Posts, parent is the path of the element
+// which holds it, nil for the destination of Map
+func collectionPath(m *Mapper, parent *fieldPath) string {
+	if parent == nil {
+		return typeName(m.Typ)
+	}
+	return parent.String() + "." + parent.m.Typ.Field(int(parent.i)).Name
+}
+
+// firstColumn returns the first column of the query which is mapped onto m, its elements are identified by it
+func firstColumn(m *Mapper) string {
+	name, index := "", -1
+	for _, c := range m.PresentColumns {
+		if index == -1 || c.columnIndex < index {
+			name, index = c.name, c.columnIndex
+		}
+	}
+	return name
+}
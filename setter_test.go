@@ -20,8 +20,8 @@ func TestSetDstAssociation(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -52,14 +52,14 @@ func TestSetDstCollection(t *testing.T) {
 	elem1 := reflect.New(m.Typ).Elem()
 	elem1.FieldByName("ID").SetInt(1)
 	elem1.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem1}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem1}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 	// User 2
 	elem2 := reflect.New(m.Typ).Elem()
 	elem2.FieldByName("ID").SetInt(2)
 	elem2.FieldByName("Name").SetString("Jane Doe")
-	rsv.elements["2"] = &element{v: elem2}
-	rsv.elementOrder = append(rsv.elementOrder, "2")
+	rsv.elements[uniqueValId{2}] = &element{v: elem2}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{2})
 
 	var users []User
 	dst := reflect.ValueOf(&users)
@@ -97,14 +97,14 @@ func TestSetDstCollectionOfPointers(t *testing.T) {
 	elem1 := reflect.New(m.Typ).Elem()
 	elem1.FieldByName("ID").SetInt(1)
 	elem1.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem1}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem1}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 	// User 2
 	elem2 := reflect.New(m.Typ).Elem()
 	elem2.FieldByName("ID").SetInt(2)
 	elem2.FieldByName("Name").SetString("Jane Doe")
-	rsv.elements["2"] = &element{v: elem2}
-	rsv.elementOrder = append(rsv.elementOrder, "2")
+	rsv.elements[uniqueValId{2}] = &element{v: elem2}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{2})
 
 	var users []*User
 	dst := reflect.ValueOf(&users)
@@ -146,18 +146,18 @@ func TestSetDstNested(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	// submap for Profile
 	profileRsv := newResolver()
 	profileElem := reflect.New(m.SubMaps[2].Typ).Elem()
 	profileElem.FieldByName("ID").SetInt(101)
 	profileElem.FieldByName("Email").SetString("john.doe@example.com")
-	profileRsv.elements["101"] = &element{v: profileElem}
-	profileRsv.elementOrder = append(profileRsv.elementOrder, "101")
+	profileRsv.elements[uniqueValId{101}] = &element{v: profileElem}
+	profileRsv.elementOrder = append(profileRsv.elementOrder, uniqueValId{101})
 
-	rsv.elements["1"].subMaps[fieldIndex(2)] = profileRsv
+	rsv.elements[uniqueValId{1}].subMaps[fieldIndex(2)] = profileRsv
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -195,18 +195,18 @@ func TestSetDstNestedPointer(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	// submap for Profile
 	profileRsv := newResolver()
 	profileElem := reflect.New(m.SubMaps[2].Typ).Elem()
 	profileElem.FieldByName("ID").SetInt(101)
 	profileElem.FieldByName("Email").SetString("john.doe@example.com")
-	profileRsv.elements["101"] = &element{v: profileElem}
-	profileRsv.elementOrder = append(profileRsv.elementOrder, "101")
+	profileRsv.elements[uniqueValId{101}] = &element{v: profileElem}
+	profileRsv.elementOrder = append(profileRsv.elementOrder, uniqueValId{101})
 
-	rsv.elements["1"].subMaps[fieldIndex(2)] = profileRsv
+	rsv.elements[uniqueValId{1}].subMaps[fieldIndex(2)] = profileRsv
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -247,8 +247,8 @@ func TestSetDstNestedCollection(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	// submap for Posts
 	postsRsv := newResolver()
@@ -256,16 +256,16 @@ func TestSetDstNestedCollection(t *testing.T) {
 	postElem1 := reflect.New(m.SubMaps[2].Typ).Elem()
 	postElem1.FieldByName("ID").SetInt(101)
 	postElem1.FieldByName("Title").SetString("First Post")
-	postsRsv.elements["101"] = &element{v: postElem1}
-	postsRsv.elementOrder = append(postsRsv.elementOrder, "101")
+	postsRsv.elements[uniqueValId{101}] = &element{v: postElem1}
+	postsRsv.elementOrder = append(postsRsv.elementOrder, uniqueValId{101})
 	// Post 2
 	postElem2 := reflect.New(m.SubMaps[2].Typ).Elem()
 	postElem2.FieldByName("ID").SetInt(102)
 	postElem2.FieldByName("Title").SetString("Second Post")
-	postsRsv.elements["102"] = &element{v: postElem2}
-	postsRsv.elementOrder = append(postsRsv.elementOrder, "102")
+	postsRsv.elements[uniqueValId{102}] = &element{v: postElem2}
+	postsRsv.elementOrder = append(postsRsv.elementOrder, uniqueValId{102})
 
-	rsv.elements["1"].subMaps[fieldIndex(2)] = postsRsv
+	rsv.elements[uniqueValId{1}].subMaps[fieldIndex(2)] = postsRsv
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -309,8 +309,8 @@ func TestSetDstNestedCollectionWithPointerSlice(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	// submap for Posts
 	postsRsv := newResolver()
@@ -318,10 +318,10 @@ func TestSetDstNestedCollectionWithPointerSlice(t *testing.T) {
 	postElem1 := reflect.New(m.SubMaps[2].Typ).Elem()
 	postElem1.FieldByName("ID").SetInt(101)
 	postElem1.FieldByName("Title").SetString("First Post")
-	postsRsv.elements["101"] = &element{v: postElem1}
-	postsRsv.elementOrder = append(postsRsv.elementOrder, "101")
+	postsRsv.elements[uniqueValId{101}] = &element{v: postElem1}
+	postsRsv.elementOrder = append(postsRsv.elementOrder, uniqueValId{101})
 
-	rsv.elements["1"].subMaps[fieldIndex(2)] = postsRsv
+	rsv.elements[uniqueValId{1}].subMaps[fieldIndex(2)] = postsRsv
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -365,8 +365,8 @@ func TestSetDstNestedCollectionWithPointerElements(t *testing.T) {
 	elem := reflect.New(m.Typ).Elem()
 	elem.FieldByName("ID").SetInt(1)
 	elem.FieldByName("Name").SetString("John Doe")
-	rsv.elements["1"] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
-	rsv.elementOrder = append(rsv.elementOrder, "1")
+	rsv.elements[uniqueValId{1}] = &element{v: elem, subMaps: make(map[fieldIndex]*resolver)}
+	rsv.elementOrder = append(rsv.elementOrder, uniqueValId{1})
 
 	// submap for Posts
 	postsRsv := newResolver()
@@ -374,10 +374,10 @@ func TestSetDstNestedCollectionWithPointerElements(t *testing.T) {
 	postElem1 := reflect.New(m.SubMaps[2].Typ).Elem()
 	postElem1.FieldByName("ID").SetInt(101)
 	postElem1.FieldByName("Title").SetString("First Post")
-	postsRsv.elements["101"] = &element{v: postElem1}
-	postsRsv.elementOrder = append(postsRsv.elementOrder, "101")
+	postsRsv.elements[uniqueValId{101}] = &element{v: postElem1}
+	postsRsv.elementOrder = append(postsRsv.elementOrder, uniqueValId{101})
 
-	rsv.elements["1"].subMaps[fieldIndex(2)] = postsRsv
+	rsv.elements[uniqueValId{1}].subMaps[fieldIndex(2)] = postsRsv
 
 	var user User
 	dst := reflect.ValueOf(&user)
@@ -0,0 +1,124 @@
+package carta
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// RowSource is a result set carta can map, *sql.Rows is adapted with FromRows, *sqlx.Rows with FromRowsx
+// and the rows of drivers with a native interface, such as pgx, with FromFieldRows.
+// In-memory sources implement RowSource directly.
+type RowSource interface {
+	Columns() ([]string, error)
+	// ColumnTypeNames returns the database type name of every column, ie "INT4", or empty names if they are not known.
+	// The type names are given to the cells of the columns, see value.Cell.DatabaseTypeName
+	ColumnTypeNames() ([]string, error)
+	Next() bool
+	// Scan copies the columns of the current row into dest, which are sql.Scanner implementations or pointers
+	// to the Go type of a column, see ColumnScanTyper
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// ColumnScanTyper is implemented by row sources which know the Go type each column is scanned into, as reported by
+// sql.ColumnType.ScanType, and whether it may hold NULL. Without de-duplication, Map scans columns straight into
+// fields of the same type, see WithoutDeduplication
+type ColumnScanTyper interface {
+	ColumnScanTypes() (types []reflect.Type, nullable []bool, err error)
+}
+
+// MultiRowSource is implemented by row sources which hold more than one result set, see MapSets
+type MultiRowSource interface {
+	RowSource
+	NextResultSet() bool
+}
+
+// FromRows adapts *sql.Rows to a RowSource
+func FromRows(rows *sql.Rows) MultiRowSource {
+	return sqlRows{rows}
+}
+
+type sqlRows struct {
+	*sql.Rows
+}
+
+func (r sqlRows) ColumnTypeNames() ([]string, error) {
+	columnTypes, err := r.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		names[i] = columnType.DatabaseTypeName()
+	}
+	return names, nil
+}
+
+func (r sqlRows) ColumnScanTypes() ([]reflect.Type, []bool, error) {
+	columnTypes, err := r.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	types := make([]reflect.Type, len(columnTypes))
+	nullable := make([]bool, len(columnTypes))
+	for i, columnType := range columnTypes {
+		types[i] = columnType.ScanType()
+		n, ok := columnType.Nullable()
+		nullable[i] = n || !ok // columns are nullable unless the driver says otherwise
+	}
+	return types, nullable, nil
+}
+
+// FieldRows is the interface of rows which describe their columns with a slice of field descriptions, such as pgx.Rows
+type FieldRows[F any] interface {
+	FieldDescriptions() []F
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close()
+}
+
+// FromFieldRows adapts rows of a driver with a native interface to a RowSource, describe returns the name and
+// database type name of a field. For example, with pgx:
+//
+//	rows, err := conn.Query(ctx, query)
+//	...
+//	err = carta.MapSource(carta.FromFieldRows(rows, func(f pgconn.FieldDescription) (string, string) {
+//		return f.Name, strconv.Itoa(int(f.DataTypeOID))
+//	}), &blogs)
+func FromFieldRows[F any](rows FieldRows[F], describe func(F) (name string, databaseTypeName string)) RowSource {
+	return &fieldRows[F]{rows: rows, describe: describe}
+}
+
+type fieldRows[F any] struct {
+	rows     FieldRows[F]
+	describe func(F) (string, string)
+}
+
+func (r *fieldRows[F]) Columns() ([]string, error) {
+	fields := r.rows.FieldDescriptions()
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i], _ = r.describe(f)
+	}
+	return names, nil
+}
+
+func (r *fieldRows[F]) ColumnTypeNames() ([]string, error) {
+	fields := r.rows.FieldDescriptions()
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		_, names[i] = r.describe(f)
+	}
+	return names, nil
+}
+
+func (r *fieldRows[F]) Next() bool                     { return r.rows.Next() }
+func (r *fieldRows[F]) Scan(dest ...interface{}) error { return r.rows.Scan(dest...) }
+func (r *fieldRows[F]) Err() error                     { return r.rows.Err() }
+
+func (r *fieldRows[F]) Close() error {
+	r.rows.Close()
+	return nil
+}
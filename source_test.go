@@ -0,0 +1,185 @@
+package carta
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sliceSource is an in-memory RowSource
+type sliceSource struct {
+	columns []string
+	rows    [][]interface{}
+	n       int
+	closed  bool
+}
+
+func (s *sliceSource) Columns() ([]string, error) { return s.columns, nil }
+
+func (s *sliceSource) ColumnTypeNames() ([]string, error) {
+	return make([]string, len(s.columns)), nil
+}
+
+func (s *sliceSource) Next() bool {
+	s.n++
+	return s.n <= len(s.rows)
+}
+
+func (s *sliceSource) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		scanner, ok := d.(sql.Scanner)
+		if !ok {
+			return fmt.Errorf("cannot scan into %T", d)
+		}
+		if err := scanner.Scan(s.rows[s.n-1][i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sliceSource) Err() error { return nil }
+
+func (s *sliceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestMapSource(t *testing.T) {
+	src := &sliceSource{
+		columns: []string{"id", "title", "author_id", "author_name"},
+		rows: [][]interface{}{
+			{int64(1), "first", int64(10), "ann"},
+			{int64(2), "second", nil, nil},
+		},
+	}
+	var blogs []Blog
+	if err := MapSource(src, &blogs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Blog{
+		{ID: 1, Title: "first", Author: Author{ID: 10, Name: "ann"}},
+		{ID: 2, Title: "second"},
+	}
+	if !reflect.DeepEqual(blogs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, blogs)
+	}
+	if !src.closed {
+		t.Error("expected MapSource to close the source")
+	}
+}
+
+// pgxField and pgxRows mimic pgconn.FieldDescription and pgx.Rows
+type pgxField struct {
+	Name        string
+	DataTypeOID uint32
+}
+
+type pgxRows struct {
+	sliceSource
+	fields []pgxField
+}
+
+func (r *pgxRows) FieldDescriptions() []pgxField { return r.fields }
+func (r *pgxRows) Close()                        { r.closed = true }
+
+func TestFromFieldRows(t *testing.T) {
+	rows := &pgxRows{
+		sliceSource: sliceSource{rows: [][]interface{}{{int64(1), "ann"}, {int64(2), "bob"}}},
+		fields:      []pgxField{{Name: "ID", DataTypeOID: 20}, {Name: "Name", DataTypeOID: 25}},
+	}
+	src := FromFieldRows[pgxField](rows, func(f pgxField) (string, string) {
+		return f.Name, fmt.Sprint(f.DataTypeOID)
+	})
+	typeNames, err := src.ColumnTypeNames()
+	if err != nil || !reflect.DeepEqual(typeNames, []string{"20", "25"}) {
+		t.Errorf("expected type names [20 25], got %v, %v", typeNames, err)
+	}
+
+	var users []User
+	if err := MapSource(src, &users); err != nil {
+		t.Fatal(err)
+	}
+	expected := []User{{ID: 1, Name: "ann"}, {ID: 2, Name: "bob"}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %+v, got %+v", expected, users)
+	}
+	if !rows.closed {
+		t.Error("expected MapSource to close the rows")
+	}
+}
+
+func TestMapSourceErrors(t *testing.T) {
+	src := &sliceSource{
+		columns: []string{"ID", "Name"},
+		rows:    [][]interface{}{{"not a number", "ann"}},
+	}
+	var users []User
+	err := MapSource(src, &users)
+	var mappingErr *MappingError
+	if !errors.As(err, &mappingErr) || mappingErr.Kind != KindConversion || mappingErr.FieldPath != "User.ID" {
+		t.Errorf("expected a conversion error on User.ID, got %v", err)
+	}
+
+	// a cell cannot hold a struct, the scan error names the column and the field it is mapped onto
+	src = &sliceSource{
+		columns: []string{"id", "title", "author_id", "author_name"},
+		rows: [][]interface{}{
+			{int64(1), "first", int64(10), "ann"},
+			{int64(2), "second", int64(11), struct{}{}},
+		},
+	}
+	var blogs []Blog
+	err = MapSource(src, &blogs)
+	if !errors.As(err, &mappingErr) || mappingErr.Kind != KindConversion || mappingErr.FieldPath != "Blog.Author.Name" ||
+		mappingErr.Column != "author_name" || mappingErr.Row != 2 || mappingErr.GoType != reflect.TypeOf("") {
+		t.Errorf("expected a conversion error on Blog.Author.Name, got %v", err)
+	}
+
+	src = &sliceSource{
+		columns: []string{"ID", "Name"},
+		rows:    [][]interface{}{{struct{}{}, "ann"}},
+	}
+	err = MapSource(src, &users)
+	if !errors.As(err, &mappingErr) || mappingErr.Kind != KindConversion || mappingErr.FieldPath != "User.ID" ||
+		mappingErr.Column != "ID" || mappingErr.Row != 1 {
+		t.Errorf("expected a conversion error on User.ID, got %v", err)
+	}
+}
+
+func TestMapSourceDriverValues(t *testing.T) {
+	type Counter struct {
+		ID    uint64  `db:"id"`
+		Ratio float32 `db:"ratio"`
+		Data  string  `db:"data"`
+	}
+	src := &sliceSource{
+		columns: []string{"id", "ratio", "data"},
+		rows: [][]interface{}{
+			{uint64(math.MaxUint64), float32(0.5), json.RawMessage(`{"a":1}`)},
+		},
+	}
+	var counters []Counter
+	if err := MapSource(src, &counters); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Counter{{ID: math.MaxUint64, Ratio: 0.5, Data: `{"a":1}`}}
+	if !reflect.DeepEqual(counters, expected) {
+		t.Errorf("expected %+v, got %+v", expected, counters)
+	}
+
+	// values of unsupported types used to be loaded as NULL
+	src = &sliceSource{
+		columns: []string{"id", "ratio", "data"},
+		rows:    [][]interface{}{{uint64(1), float32(0.5), []int{1}}},
+	}
+	err := MapSource(src, &counters)
+	if err == nil || errors.Is(err, ErrNullToNonNullable) || !strings.Contains(err.Error(), "cannot scan []int") {
+		t.Errorf("expected a scan error, got %v", err)
+	}
+}
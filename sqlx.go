@@ -1,9 +1,59 @@
 package carta
 
-import "github.com/jmoiron/sqlx"
+import (
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
 
 // Mapx maps sqlx.Rows onto a struct or slice of structs.
-// It is a convenience wrapper around the Map function.
-func Mapx(rows *sqlx.Rows, dst interface{}) error {
-	return Map(rows.Rows, dst)
+// It is a convenience wrapper around the Map function, which also matches fields by the names
+// the sqlx mapper of rows gives them, ie after db.MapperFunc(strings.ToUpper)
+func Mapx(rows *sqlx.Rows, dst interface{}, opts ...MapOption) error {
+	if rows.Mapper != nil {
+		opts = append([]MapOption{WithFieldNamer(SqlxFieldNamer{rows.Mapper})}, opts...)
+	}
+	return Map(rows.Rows, dst, opts...)
+}
+
+// FromRowsx adapts sqlx.Rows to a RowSource
+func FromRowsx(rows *sqlx.Rows) MultiRowSource {
+	return FromRows(rows.Rows)
+}
+
+// SqlxFieldNamer is a FieldNamer which names fields as a sqlx mapper does, with its tag key and NameMapper
+type SqlxFieldNamer struct {
+	Mapper *reflectx.Mapper
+}
+
+func (n SqlxFieldNamer) FieldName(t reflect.Type, i int) string {
+	fi := n.Mapper.TypeMap(t).Tree.Children[i]
+	if fi == nil {
+		return ""
+	}
+	return fi.Name
+}
+
+// Selectx runs query with q and maps its rows onto dst with Mapx, q is usually a *sqlx.DB or *sqlx.Tx.
+// MapOption values among args configure the mapping rather than being passed to the query, as they do for Query
+func Selectx(q sqlx.Queryer, dst interface{}, query string, args ...interface{}) error {
+	args, opts := splitMapOptions(args)
+	rows, err := q.Queryx(query, args...)
+	if err != nil {
+		return err
+	}
+	return Mapx(rows, dst, opts...)
+}
+
+// NamedSelectx binds the named parameters of query from arg, a struct or map, as sqlx.NamedQuery does,
+// runs it with e and maps its rows onto dst with Mapx
+//
+//	err := carta.NamedSelectx(db, &blogs, `SELECT ... WHERE b.author_id = :author_id`, map[string]interface{}{"author_id": 1})
+func NamedSelectx(e sqlx.Ext, dst interface{}, query string, arg interface{}, opts ...MapOption) error {
+	rows, err := sqlx.NamedQuery(e, query, arg)
+	if err != nil {
+		return err
+	}
+	return Mapx(rows, dst, opts...)
 }
@@ -1,9 +1,11 @@
 package carta
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
@@ -74,3 +76,93 @@ func TestMapx(t *testing.T) {
 	assert.Equal(t, 1, blogs[0].Author.Id)
 	assert.Equal(t, "johndoe", blogs[0].Author.Username)
 }
+
+func TestMapSourceFromRowsx(t *testing.T) {
+	db := setupSqlxDB(t)
+	defer db.Close()
+
+	rows, err := db.Queryx(`SELECT b.id, b.title, a.id AS "author->id", a.username AS "author->username" FROM blog b JOIN author a ON b.author_id = a.id`)
+	assert.NoError(t, err)
+
+	var blogs []SqlxBlog
+	err = MapSource(FromRowsx(rows), &blogs)
+	assert.NoError(t, err)
+	assert.Equal(t, []SqlxBlog{{Id: 1, Title: "My First Post", Author: SqlxAuthor{Id: 1, Username: "johndoe"}}}, blogs)
+}
+
+type SqlxUser struct {
+	UserId   int
+	UserName string `json:"handle"`
+}
+
+func TestMapxNameMapper(t *testing.T) {
+	db := setupSqlxDB(t)
+	defer db.Close()
+
+	// USERID and USERNAME are only matched through the sqlx mapper
+	db.MapperFunc(strings.ToUpper)
+	rows, err := db.Queryx(`SELECT id AS USERID, username AS USERNAME FROM author`)
+	assert.NoError(t, err)
+	var users []SqlxUser
+	err = Mapx(rows, &users)
+	assert.NoError(t, err)
+	assert.Equal(t, []SqlxUser{{UserId: 1, UserName: "johndoe"}}, users)
+
+	// the tag key of the sqlx mapper is honoured too
+	db.Mapper = reflectx.NewMapperFunc("json", strings.ToLower)
+	rows, err = db.Queryx(`SELECT id AS userid, username AS handle FROM author`)
+	assert.NoError(t, err)
+	users = nil
+	err = Mapx(rows, &users)
+	assert.NoError(t, err)
+	assert.Equal(t, []SqlxUser{{UserId: 1, UserName: "johndoe"}}, users)
+
+	// without the sqlx mapper, the column is not matched
+	rows, err = db.Queryx(`SELECT id AS userid, username AS handle FROM author`)
+	assert.NoError(t, err)
+	users = nil
+	err = Map(rows.Rows, &users)
+	assert.NoError(t, err)
+	assert.Equal(t, []SqlxUser{{UserId: 1}}, users)
+}
+
+func TestSelectx(t *testing.T) {
+	db := setupSqlxDB(t)
+	defer db.Close()
+
+	query := `SELECT b.id, b.title, a.id AS "author->id", a.username AS "author->username" FROM blog b JOIN author a ON b.author_id = a.id`
+	expected := []SqlxBlog{{Id: 1, Title: "My First Post", Author: SqlxAuthor{Id: 1, Username: "johndoe"}}}
+
+	var blogs []SqlxBlog
+	err := Selectx(db, &blogs, query+" WHERE b.id = ?", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, blogs)
+
+	blogs = nil
+	err = NamedSelectx(db, &blogs, query+" WHERE a.username = :username", SqlxAuthor{Username: "johndoe"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, blogs)
+
+	blogs = nil
+	err = NamedSelectx(db, &blogs, query+" WHERE a.username = :username", map[string]interface{}{"username": "nobody"})
+	assert.NoError(t, err)
+	assert.Empty(t, blogs)
+}
+
+func TestSelectxOptions(t *testing.T) {
+	db := setupSqlxDB(t)
+	defer db.Close()
+
+	query := "SELECT id, username FROM author UNION ALL SELECT id, username FROM author"
+	expected := []SqlxAuthor{{Id: 1, Username: "johndoe"}, {Id: 1, Username: "johndoe"}}
+
+	var authors []SqlxAuthor
+	err := Selectx(db, &authors, query+" WHERE id = ?", 1, WithoutDeduplication())
+	assert.NoError(t, err)
+	assert.Equal(t, expected, authors)
+
+	authors = nil
+	err = NamedSelectx(db, &authors, query+" WHERE id = :id", map[string]interface{}{"id": 1}, WithoutDeduplication())
+	assert.NoError(t, err)
+	assert.Equal(t, expected, authors)
+}
@@ -0,0 +1,222 @@
+package carta
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProblemKind classifies a problem found by Validate
+type ProblemKind int
+
+const (
+	UnmappedColumn   ProblemKind = iota + 1 // a column is not mapped onto any field
+	UnreachableField                        // a basic field is not mapped from any column
+	UnsupportedField                        // an exported field has a type carta cannot map
+	AmbiguousColumn                         // a column matches more than one field
+	MissingIdentity                         // a struct has no columns which identify its entities
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case UnmappedColumn:
+		return "unmapped column"
+	case UnreachableField:
+		return "unreachable field"
+	case UnsupportedField:
+		return "unsupported field"
+	case AmbiguousColumn:
+		return "ambiguous column"
+	case MissingIdentity:
+		return "missing identity"
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(k))
+}
+
+// Problem is a single incompatibility between a column list and a destination type
+type Problem struct {
+	Kind      ProblemKind
+	Column    string // column the problem is about, if any
+	FieldPath string // Go path of the field or struct the problem is about, if any
+	Message   string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	b.WriteString(p.Kind.String())
+	if p.FieldPath != "" {
+		b.WriteString(" " + p.FieldPath)
+	}
+	if p.Column != "" {
+		fmt.Fprintf(&b, " (column %s)", p.Column)
+	}
+	if p.Message != "" {
+		b.WriteString(": " + p.Message)
+	}
+	return b.String()
+}
+
+// ValidationError is returned by Validate when the columns are not compatible with the destination type
+type ValidationError struct {
+	Type     string // destination type
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "carta: %d problem(s) mapping columns onto %s", len(e.Problems), e.Type)
+	for _, p := range e.Problems {
+		b.WriteString("\n\t" + p.String())
+	}
+	return b.String()
+}
+
+// Is reports whether target is ErrColumnMismatch, or ErrAmbiguousColumn if one of the problems is an ambiguous column
+func (e *ValidationError) Is(target error) bool {
+	if target == ErrColumnMismatch {
+		return true
+	}
+	if target == ErrAmbiguousColumn {
+		for _, p := range e.Problems {
+			if p.Kind == AmbiguousColumn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type validateConfig struct {
+	ignoreColumns          map[string]bool
+	ignoreFields           []string
+	allowUnmappedColumns   bool
+	allowUnreachableFields bool
+}
+
+// ValidateOption relaxes the checks made by Validate
+type ValidateOption func(*validateConfig)
+
+// IgnoreColumns allows the named columns to be unmapped
+func IgnoreColumns(names ...string) ValidateOption {
+	return func(c *validateConfig) {
+		for _, name := range names {
+			c.ignoreColumns[name] = true
+		}
+	}
+}
+
+// IgnoreFields skips the fields with the given Go paths, ie "Blog.CreatedAt", as well as anything nested below them
+func IgnoreFields(paths ...string) ValidateOption {
+	return func(c *validateConfig) {
+		c.ignoreFields = append(c.ignoreFields, paths...)
+	}
+}
+
+// AllowUnmappedColumns allows columns which are not mapped onto any field
+func AllowUnmappedColumns() ValidateOption {
+	return func(c *validateConfig) {
+		c.allowUnmappedColumns = true
+	}
+}
+
+// AllowUnreachableFields allows basic fields which are not mapped from any column, ie when a query selects a subset of the fields
+func AllowUnreachableFields() ValidateOption {
+	return func(c *validateConfig) {
+		c.allowUnreachableFields = true
+	}
+}
+
+func (c *validateConfig) ignored(path string) bool {
+	for _, p := range c.ignoreFields {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks, without any rows, that a result set with the given columns can be mapped onto a T,
+// where T is the type which would be passed to Map as &dst, ie Validate[[]Blog](columns).
+// It reports unmapped columns, unreachable fields, unsupported field types, ambiguous columns and structs which
+// have no columns to identify their entities. All problems are collected into a *ValidationError.
+//
+// Validate is intended to be called from unit tests, with the column list of every query, so that
+// changes to a query or a struct which break the mapping fail in CI:
+//
+//	if err := carta.Validate[[]Blog]([]string{"id", "title", "author->id"}); err != nil {
+//		t.Fatal(err)
+//	}
+func Validate[T any](columns []string, opts ...ValidateOption) error {
+	config := &validateConfig{ignoreColumns: map[string]bool{}}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	plan, err := Explain(columns, (*T)(nil))
+	if err != nil {
+		var mErr *MappingError
+		if errors.As(err, &mErr) && mErr.Kind == KindAmbiguousColumn {
+			return &ValidationError{
+				Type: fmt.Sprintf("%T", (*T)(nil)),
+				Problems: []Problem{{
+					Kind:      AmbiguousColumn,
+					Column:    mErr.Column,
+					FieldPath: mErr.FieldPath,
+					Message:   mErr.Err.Error(),
+				}},
+			}
+		}
+		return err
+	}
+
+	problems := []Problem{}
+	if !config.allowUnmappedColumns {
+		for _, c := range plan.UnclaimedColumns {
+			if !config.ignoreColumns[c] {
+				problems = append(problems, Problem{Kind: UnmappedColumn, Column: c, Message: "column is not mapped onto any field"})
+			}
+		}
+	}
+	problems = validateNode(plan.Root, config, problems)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Type: plan.Root.Type, Problems: problems}
+}
+
+func validateNode(n *PlanNode, config *validateConfig, problems []Problem) []Problem {
+	if config.ignored(n.Path) {
+		return problems
+	}
+	if !n.Basic && len(n.IdentityColumns) == 0 {
+		problems = append(problems, Problem{
+			Kind:      MissingIdentity,
+			FieldPath: n.Path,
+			Message:   "no columns are mapped onto this struct, its entities cannot be told apart",
+		})
+	}
+	for _, f := range n.Fields {
+		path := n.Path + "." + f.Name
+		if n.Basic {
+			path = n.Path
+		}
+		if f.Column == "" && !config.allowUnreachableFields && !config.ignored(path) {
+			problems = append(problems, Problem{
+				Kind:      UnreachableField,
+				FieldPath: path,
+				Message:   "no column matches " + strings.Join(f.Candidates, ", "),
+			})
+		}
+	}
+	for _, name := range n.UnsupportedFields {
+		path := n.Path + "." + name
+		if !config.ignored(path) {
+			problems = append(problems, Problem{Kind: UnsupportedField, FieldPath: path, Message: "carta cannot map this type"})
+		}
+	}
+	for _, child := range n.Children {
+		problems = validateNode(child, config, problems)
+	}
+	return problems
+}
@@ -0,0 +1,84 @@
+package carta
+
+import (
+	"errors"
+	"testing"
+)
+
+type BlogWithMetadata struct {
+	ID       int               `db:"id"`
+	Title    string            `db:"title"`
+	Metadata map[string]string `db:"metadata"`
+	Author   Author            `carta:"author"`
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate[[]Blog]([]string{"id", "title", "author_id", "author_name"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	err := Validate[[]BlogWithMetadata]([]string{"id", "extra"})
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	if !errors.Is(err, ErrColumnMismatch) {
+		t.Errorf("expected error to match ErrColumnMismatch")
+	}
+
+	expected := map[ProblemKind][]string{
+		UnmappedColumn:   {"extra"},
+		UnreachableField: {"BlogWithMetadata.Title", "BlogWithMetadata.Author.ID", "BlogWithMetadata.Author.Name"},
+		UnsupportedField: {"BlogWithMetadata.Metadata"},
+		MissingIdentity:  {"BlogWithMetadata.Author"},
+	}
+	found := map[ProblemKind][]string{}
+	for _, p := range vErr.Problems {
+		subject := p.FieldPath
+		if p.Kind == UnmappedColumn {
+			subject = p.Column
+		}
+		found[p.Kind] = append(found[p.Kind], subject)
+	}
+	for kind, subjects := range expected {
+		if len(found[kind]) != len(subjects) {
+			t.Errorf("expected %s problems %v, got %v", kind, subjects, found[kind])
+			continue
+		}
+		for i := range subjects {
+			if found[kind][i] != subjects[i] {
+				t.Errorf("expected %s problems %v, got %v", kind, subjects, found[kind])
+				break
+			}
+		}
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	err := Validate[[]BlogWithMetadata](
+		[]string{"id", "title", "extra"},
+		IgnoreColumns("extra"),
+		IgnoreFields("BlogWithMetadata.Metadata", "BlogWithMetadata.Author"),
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err = Validate[[]Blog]([]string{"id", "author_id", "extra"}, AllowUnmappedColumns(), AllowUnreachableFields())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateAmbiguousColumn(t *testing.T) {
+	err := Validate[[]BlogWithAuthorID]([]string{"id", "author_id", "author_name"})
+	if !errors.Is(err, ErrAmbiguousColumn) {
+		t.Fatalf("expected error to match ErrAmbiguousColumn, got %v", err)
+	}
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || vErr.Problems[0].Kind != AmbiguousColumn || vErr.Problems[0].Column != "author_id" {
+		t.Errorf("expected an ambiguous column problem for author_id, got %v", err)
+	}
+}
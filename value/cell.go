@@ -2,12 +2,17 @@ package value
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"math"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -19,8 +24,11 @@ import (
 type Cell struct {
 	kind       reflect.Kind // data type with which Cell will be instantiated
 	bits       uint64       //IEEE 754 binary representation of numeric value
-	text       string       // non-numeric data as bytes for data which arrives as string or []byte
+	text       string       // non-numeric data which arrives as string
+	raw        []byte       // non-numeric data which arrives as []byte, the buffer is reused when the cell is scanned again
+	isRaw      bool         // the data is held in raw rather than text, a string is only built if the data is read as one
 	time       time.Time    //  any data that arrives as time, that includes timestame w/ or w/o zone
+	src        interface{}  // value passed to Scan, nil if the cell was set otherwise or the value is held in raw
 	colTypName string       // Used for parting if some data arrices in plain text format, ex, if time arrives as string
 	valid      bool
 }
@@ -42,9 +50,34 @@ func NewCellWithData(colTypName string, data interface{}) *Cell {
 	return c
 }
 
-// implements database/sql scan interface
+// Scan implements sql.Scanner. It accepts the values database/sql drivers return (int64, float64, bool, []byte,
+// string, time.Time and nil) as well as other integer and float types, named types of those kinds, pointers,
+// driver.Valuer implementations, *big.Int and fmt.Stringer implementations such as decimal types, which are held
+// as text. Any other type is an error rather than NULL.
 func (c *Cell) Scan(src interface{}) error {
+	if err := c.scan(src, 0); err != nil {
+		c.SetNull()
+		return err
+	}
+	c.setSrc(src)
+	return nil
+}
+
+// setSrc records the value scanned into the cell for Raw, unless it is held in raw or a driver.Valuer recorded the value
+// it returned
+func (c *Cell) setSrc(src interface{}) {
+	if c.valid && !c.isRaw && c.src == nil {
+		c.src = src
+	}
+}
+
+// maxValuerDepth bounds the chain of driver.Valuer implementations which return another driver.Valuer
+const maxValuerDepth = 8
+
+func (c *Cell) scan(src interface{}, depth int) error {
 	switch v := src.(type) {
+	case nil:
+		c.SetNull()
 	case int:
 		c.SetInt64(int64(v))
 	case int8:
@@ -55,24 +88,108 @@ func (c *Cell) Scan(src interface{}) error {
 		c.SetInt64(int64(v))
 	case int64:
 		c.SetInt64(v)
+	case uint:
+		c.SetUint64(uint64(v))
+	case uint8:
+		c.SetUint64(uint64(v))
+	case uint16:
+		c.SetUint64(uint64(v))
+	case uint32:
+		c.SetUint64(uint64(v))
+	case uint64:
+		c.SetUint64(v)
+	case float32:
+		c.SetFloat64(float64(v))
 	case float64:
 		c.SetFloat64(v)
 	case bool:
 		c.SetBool(v)
 	case []byte:
-		c.SetString(string(v))
+		c.SetBytes(v)
+	case json.RawMessage:
+		c.SetBytes(v)
 	case string:
 		c.SetString(v)
 	case time.Time:
 		c.SetTime(v)
+	case *big.Int:
+		switch {
+		case v == nil:
+			c.SetNull()
+		case v.IsInt64():
+			c.SetInt64(v.Int64())
+		case v.IsUint64():
+			c.SetUint64(v.Uint64())
+		default:
+			c.SetString(v.String())
+		}
+	case driver.Valuer:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			c.SetNull()
+			return nil
+		}
+		if depth >= maxValuerDepth {
+			return fmt.Errorf("cannot scan %T, its Value method returns too many nested driver.Valuer", src)
+		}
+		d, err := v.Value()
+		if err != nil {
+			return err
+		}
+		if err := c.scan(d, depth+1); err != nil {
+			return err
+		}
+		c.setSrc(d) // Raw returns the value of the innermost driver.Valuer
+		return nil
 	default:
-		// src is nil
-		c.SetNull()
+		return c.scanReflect(src, depth)
+	}
+	return nil
+}
+
+// scanReflect scans named types of the kinds Scan accepts, ie type Level int, pointers, and fmt.Stringer implementations
+func (c *Cell) scanReflect(src interface{}, depth int) error {
+	rv := reflect.ValueOf(src)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.SetInt64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		c.SetUint64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		c.SetFloat64(rv.Float())
+	case reflect.Bool:
+		c.SetBool(rv.Bool())
+	case reflect.String:
+		c.SetString(rv.String())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("cannot scan %T", src)
+		}
+		c.SetBytes(rv.Bytes())
+	case reflect.Ptr:
+		if rv.IsNil() {
+			c.SetNull()
+			return nil
+		}
+		err := c.scan(rv.Elem().Interface(), depth)
+		if stringer, ok := src.(fmt.Stringer); ok && err != nil {
+			// types such as *decimal.Big only implement fmt.Stringer with a pointer receiver
+			c.SetString(stringer.String())
+			return nil
+		}
+		return err
+	default:
+		if stringer, ok := src.(fmt.Stringer); ok {
+			c.SetString(stringer.String())
+			return nil
+		}
+		return fmt.Errorf("cannot scan %T", src)
 	}
 	return nil
 }
 
 func (c *Cell) SetBool(d bool) {
+	c.src = nil
+	c.isRaw = false
 	c.kind = reflect.Bool
 	c.valid = true
 	if d {
@@ -83,24 +200,51 @@ func (c *Cell) SetBool(d bool) {
 }
 
 func (c *Cell) SetFloat64(d float64) {
+	c.src = nil
+	c.isRaw = false
 	c.kind = reflect.Float64
 	c.valid = true
 	c.bits = math.Float64bits(d)
 }
 
 func (c *Cell) SetInt64(d int64) {
+	c.src = nil
+	c.isRaw = false
 	c.kind = reflect.Int64
 	c.valid = true
 	c.bits = uint64(d)
 }
 
 func (c *Cell) SetString(d string) {
+	c.src = nil
 	c.kind = reflect.String
 	c.valid = true
 	c.text = d
+	c.isRaw = false
+}
+
+// SetBytes copies d into the cell, d may be reused by the caller once SetBytes returns
+func (c *Cell) SetBytes(d []byte) {
+	c.kind = reflect.String
+	c.valid = true
+	c.raw = append(c.raw[:0], d...)
+	c.text = ""
+	c.isRaw = true
+	c.src = nil
+}
+
+// SetUint64 holds unsigned integers which may not fit into an int64
+func (c *Cell) SetUint64(d uint64) {
+	c.src = nil
+	c.isRaw = false
+	c.kind = reflect.Uint64
+	c.valid = true
+	c.bits = d
 }
 
 func (c *Cell) SetTime(d time.Time) {
+	c.src = nil
+	c.isRaw = false
 	c.kind = reflect.Struct
 	c.valid = true
 	c.time = d
@@ -108,6 +252,8 @@ func (c *Cell) SetTime(d time.Time) {
 
 func (c *Cell) SetNull() {
 	c.valid = false
+	c.src = nil
+	c.isRaw = false
 }
 
 func (c Cell) Kind() reflect.Kind {
@@ -122,13 +268,46 @@ func (c Cell) IsValid() bool {
 	return c.valid
 }
 
+// DatabaseTypeName returns the database type of the column the cell was created for, ie "INT4", or "" if it is not known
+func (c Cell) DatabaseTypeName() string {
+	return c.colTypName
+}
+
+// Raw returns the value the driver scanned into the cell, ie an int64, float64, bool, []byte, string or time.Time,
+// or nil for NULL. The value returned by a driver.Valuer is returned rather than the driver.Valuer itself. A []byte is held by the cell and is only valid until the cell is scanned again, use Bytes for a copy.
+// The value of a cell which was set rather than scanned is returned as AsInterface would
+func (c Cell) Raw() interface{} {
+	if !c.valid {
+		return nil
+	}
+	if c.isRaw && c.kind == reflect.String {
+		return c.raw
+	}
+	if c.src != nil {
+		return c.src
+	}
+	i, _ := c.AsInterface()
+	return i
+}
+
+// Bytes returns a copy of the text or binary data held by the cell
+func (c Cell) Bytes() ([]byte, error) {
+	if c.kind != reflect.String {
+		return nil, fmt.Errorf("cannot convert %v to []byte", c.kind)
+	}
+	if c.isRaw {
+		return append([]byte(nil), c.raw...), nil
+	}
+	return []byte(c.text), nil
+}
+
 func (c Cell) Bool() (bool, error) {
 	return (c.bits != 0), nil
 }
 
 func (c Cell) Int32() (int32, error) {
 	if c.kind == reflect.String {
-		if num, err := strconv.ParseInt(c.text, 10, 32); err != nil {
+		if num, err := strconv.ParseInt(c.str(), 10, 32); err != nil {
 			return 0, err
 		} else {
 			return int32(num), nil
@@ -139,7 +318,7 @@ func (c Cell) Int32() (int32, error) {
 
 func (c Cell) Int64() (int64, error) {
 	if c.kind == reflect.String {
-		if num, err := strconv.ParseInt(c.text, 10, 64); err != nil {
+		if num, err := strconv.ParseInt(c.str(), 10, 64); err != nil {
 			return 0, err
 		} else {
 			return int64(num), nil
@@ -148,45 +327,71 @@ func (c Cell) Int64() (int64, error) {
 	if c.kind == reflect.Float64 {
 		return int64(math.Float64frombits(c.bits)), nil
 	}
+	if c.kind == reflect.Uint64 && c.bits > math.MaxInt64 {
+		return 0, OverflowErr(c.bits, reflect.TypeOf(int64(0)))
+	}
 	return int64(c.bits), nil
 }
 
 func (c Cell) Uint32() (uint32, error) {
 	if c.kind == reflect.String {
-		if num, err := strconv.ParseUint(c.text, 10, 32); err != nil {
+		if num, err := strconv.ParseUint(c.str(), 10, 32); err != nil {
 			return 0, err
 		} else {
 			return uint32(num), nil
 		}
 	}
-	return uint32(c.bits), nil
+	num, err := c.Uint64()
+	if err != nil {
+		return 0, err
+	}
+	if num > math.MaxUint32 {
+		return 0, OverflowErr(num, reflect.TypeOf(uint32(0)))
+	}
+	return uint32(num), nil
 }
 
+// Uint64 returns the full range of unsigned integers, negative numbers are an error
 func (c Cell) Uint64() (uint64, error) {
-	if c.kind == reflect.String {
-		if num, err := strconv.ParseUint(c.text, 10, 64); err != nil {
+	switch c.kind {
+	case reflect.String:
+		if num, err := strconv.ParseUint(c.str(), 10, 64); err != nil {
 			return 0, err
 		} else {
 			return uint64(num), nil
 		}
+	case reflect.Int64:
+		if int64(c.bits) < 0 {
+			return 0, OverflowErr(int64(c.bits), reflect.TypeOf(uint64(0)))
+		}
+	case reflect.Float64:
+		f := math.Float64frombits(c.bits)
+		if f < 0 || f >= math.MaxUint64 {
+			return 0, OverflowErr(f, reflect.TypeOf(uint64(0)))
+		}
+		return uint64(f), nil
 	}
 	return c.bits, nil
 }
 
 func (c Cell) Float32() (float32, error) {
 	if c.kind == reflect.String {
-		if num, err := strconv.ParseFloat(c.text, 32); err != nil {
+		if num, err := strconv.ParseFloat(c.str(), 32); err != nil {
 			return 0, err
 		} else {
 			return float32(num), nil
 		}
 	}
-	return math.Float32frombits(uint32(c.bits)), nil
+	if c.kind == reflect.Float32 {
+		return math.Float32frombits(uint32(c.bits)), nil
+	}
+	num, err := c.Float64()
+	return float32(num), err
 }
 
 func (c Cell) Float64() (float64, error) {
 	if c.kind == reflect.String {
-		if num, err := strconv.ParseFloat(c.text, 64); err != nil {
+		if num, err := strconv.ParseFloat(c.str(), 64); err != nil {
 			return 0, err
 		} else {
 			return num, nil
@@ -195,11 +400,142 @@ func (c Cell) Float64() (float64, error) {
 	if c.kind == reflect.Int64 {
 		return float64(int64(c.bits)), nil
 	}
+	if c.kind == reflect.Uint64 {
+		return float64(c.bits), nil
+	}
 	return math.Float64frombits(c.bits), nil
 }
 
 func (c Cell) String() (string, error) {
-	return c.text, nil
+	return c.str(), nil
+}
+
+func (c Cell) str() string {
+	if c.isRaw {
+		return string(c.raw)
+	}
+	return c.text
+}
+
+// BigInt returns the integer held by the cell. Text is parsed in base 10, and may have a fractional part of zeros or
+// an exponent, ie "12.00" or "1e30", but is never converted to a float64
+func (c Cell) BigInt() (*big.Int, error) {
+	switch c.kind {
+	case reflect.String:
+		s := c.str()
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return i, nil
+		}
+		if r, ok := new(big.Rat).SetString(s); ok && r.IsInt() {
+			return new(big.Int).Set(r.Num()), nil
+		}
+		return nil, fmt.Errorf("cannot parse %q as an integer", s)
+	case reflect.Int64:
+		return big.NewInt(int64(c.bits)), nil
+	case reflect.Uint64:
+		return new(big.Int).SetUint64(c.bits), nil
+	case reflect.Float64:
+		f := math.Float64frombits(c.bits)
+		if math.IsInf(f, 0) || math.IsNaN(f) || f != math.Trunc(f) {
+			return nil, fmt.Errorf("cannot convert %v to an integer", f)
+		}
+		i, _ := new(big.Float).SetFloat64(f).Int(nil)
+		return i, nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to an integer", c.kind)
+}
+
+// BigRat returns the exact value of the number held by the cell, text such as "12.34", "1e-3" or "1/3" is parsed
+// without going through a float64
+func (c Cell) BigRat() (*big.Rat, error) {
+	switch c.kind {
+	case reflect.String:
+		s := c.str()
+		if r, ok := new(big.Rat).SetString(s); ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("cannot parse %q as a number", s)
+	case reflect.Int64:
+		return new(big.Rat).SetInt64(int64(c.bits)), nil
+	case reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(c.bits)), nil
+	case reflect.Float64:
+		f := math.Float64frombits(c.bits)
+		if r := new(big.Rat).SetFloat64(f); r != nil {
+			return r, nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to a rational number", f)
+	}
+	return nil, fmt.Errorf("cannot convert %v to a rational number", c.kind)
+}
+
+// BigFloat returns the number held by the cell. Text is parsed with enough precision for every digit it holds,
+// at least 64 bits, rather than the 53 bits of a float64
+func (c Cell) BigFloat() (*big.Float, error) {
+	switch c.kind {
+	case reflect.String:
+		s := c.str()
+		prec := uint(len(s)) * 4 // more than log2(10) bits per digit
+		if prec < 64 {
+			prec = 64
+		}
+		f, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q as a number: %w", s, err)
+		}
+		return f, nil
+	case reflect.Int64:
+		return new(big.Float).SetInt64(int64(c.bits)), nil
+	case reflect.Uint64:
+		return new(big.Float).SetUint64(c.bits), nil
+	case reflect.Float64:
+		f := math.Float64frombits(c.bits)
+		if math.IsNaN(f) {
+			return nil, errors.New("cannot convert NaN to a big.Float")
+		}
+		return new(big.Float).SetFloat64(f), nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to a number", c.kind)
+}
+
+// UUID returns the UUID held by the cell, which may be 16 bytes of binary data, ie a MySQL BINARY(16) column, or text
+// of 36 characters in the 8-4-4-4-12 form, or of 32 hex digits. The binary form of a SQL Server UNIQUEIDENTIFIER column,
+// whose first three groups are little-endian, is converted to the byte order of the text form
+func (c Cell) UUID() ([16]byte, error) {
+	var u [16]byte
+	if c.kind != reflect.String {
+		return u, fmt.Errorf("cannot convert %v to a UUID", c.kind)
+	}
+	b := c.raw
+	if !c.isRaw {
+		b = []byte(c.text)
+	}
+	switch len(b) {
+	case 16:
+		copy(u[:], b)
+		if strings.EqualFold(c.colTypName, UniqueIdentifier) {
+			u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+			u[4], u[5] = u[5], u[4]
+			u[6], u[7] = u[7], u[6]
+		}
+		return u, nil
+	case 36:
+		if b[8] != '-' || b[13] != '-' || b[18] != '-' || b[23] != '-' {
+			break
+		}
+		digits := make([]byte, 0, 32)
+		for _, group := range [][]byte{b[:8], b[9:13], b[14:18], b[19:23], b[24:]} {
+			digits = append(digits, group...)
+		}
+		if _, err := hex.Decode(u[:], digits); err == nil {
+			return u, nil
+		}
+	case 32:
+		if _, err := hex.Decode(u[:], b); err == nil {
+			return u, nil
+		}
+	}
+	return u, fmt.Errorf("cannot parse %q as a UUID", b)
 }
 
 func (c Cell) Time() (time.Time, error) {
@@ -207,7 +543,7 @@ func (c Cell) Time() (time.Time, error) {
 		// TODO: Parse from string
 		// switch c.colTypName {
 		// }
-		log.Println(c.text, " >aslkdfjaslkfj")
+		log.Println(c.str(), " >aslkdfjaslkfj")
 		return time.Time{}, errors.New("cannot convert time data which arrived as string or []uint8 from sql")
 	}
 	return c.time, nil
@@ -313,31 +649,61 @@ func (c Cell) AsInterface() (interface{}, error) {
 		i, err = c.Float64()
 	case reflect.String:
 		i, err = c.String()
+	case reflect.Struct:
+		i, err = c.Time()
 	}
 	return i, err
 }
 
+// Uid returns a key which identifies the value of the cell, used to tell apart the entities of a result set.
+// Keys are tagged with the kind of the value and are self-delimiting, so that the keys of several
+// cells can be concatenated without collisions: strings are length-prefixed, numbers and times end with ';'.
+// Times are encoded with nanosecond precision, and equal instants in different locations have equal keys.
 func (c Cell) Uid() string {
+	return string(c.AppendUid(nil))
+}
+
+// AppendUid appends the key returned by Uid to b, without allocating if b has enough capacity
+func (c *Cell) AppendUid(b []byte) []byte {
 	if c.IsNull() {
-		//TODO: safely represent null and bool values as string
-		return "cnull"
-	} else {
-		switch c.kind {
-		case reflect.Int64, reflect.Float64:
-			return strconv.FormatUint(c.bits, 36)
-		case reflect.String:
-			return c.text
-		case reflect.Bool:
-			if c.bits != 0 {
-				return "ctrue"
-			} else {
-				return "cfalse"
-			}
-		case reflect.Struct:
-			return strconv.FormatInt(c.time.Unix(), 36)
-		}
-	}
-	return ""
+		return append(b, 'n')
+	}
+	switch c.kind {
+	case reflect.Int64:
+		b = append(b, 'i')
+		b = strconv.AppendUint(b, c.bits, 36)
+		return append(b, ';')
+	case reflect.Uint64:
+		b = append(b, 'u')
+		b = strconv.AppendUint(b, c.bits, 36)
+		return append(b, ';')
+	case reflect.Float64:
+		b = append(b, 'f')
+		b = strconv.AppendUint(b, c.bits, 36)
+		return append(b, ';')
+	case reflect.String:
+		b = append(b, 's')
+		if c.isRaw {
+			b = strconv.AppendInt(b, int64(len(c.raw)), 10)
+			b = append(b, ':')
+			return append(b, c.raw...)
+		}
+		b = strconv.AppendInt(b, int64(len(c.text)), 10)
+		b = append(b, ':')
+		return append(b, c.text...)
+	case reflect.Bool:
+		if c.bits != 0 {
+			return append(b, 't')
+		}
+		return append(b, 'b')
+	case reflect.Struct:
+		b = append(b, 'T')
+		b = strconv.AppendInt(b, c.time.Unix(), 36)
+		b = append(b, '.')
+		b = strconv.AppendInt(b, int64(c.time.Nanosecond()), 36)
+		return append(b, ';')
+	}
+	return b
 }
 
 // func (c Cell) BitsAsString() string {
@@ -2,7 +2,11 @@ package value
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"testing"
 	"time"
@@ -124,8 +128,8 @@ func TestCell_Scan(t *testing.T) {
 			if cell.bits != tc.expected.bits {
 				t.Errorf("expected bits %v, got %v", tc.expected.bits, cell.bits)
 			}
-			if cell.text != tc.expected.text {
-				t.Errorf("expected text %q, got %q", tc.expected.text, cell.text)
+			if cell.str() != tc.expected.str() {
+				t.Errorf("expected text %q, got %q", tc.expected.str(), cell.str())
 			}
 			if !cell.time.Equal(tc.expected.time) {
 				t.Errorf("expected time %v, got %v", tc.expected.time, cell.time)
@@ -417,7 +421,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetNull()
 				return c
 			},
-			expected: "cnull",
+			expected: "n",
 		},
 		{
 			name: "Bool true UID",
@@ -426,7 +430,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetBool(true)
 				return c
 			},
-			expected: "ctrue",
+			expected: "t",
 		},
 		{
 			name: "Bool false UID",
@@ -435,7 +439,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetBool(false)
 				return c
 			},
-			expected: "cfalse",
+			expected: "b",
 		},
 		{
 			name: "String UID",
@@ -444,7 +448,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetString("hello")
 				return c
 			},
-			expected: "hello",
+			expected: "s5:hello",
 		},
 		{
 			name: "Int64 UID",
@@ -453,7 +457,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetInt64(123456789)
 				return c
 			},
-			expected: "21i3v9",
+			expected: "i21i3v9;",
 		},
 		{
 			name: "Float64 UID",
@@ -462,7 +466,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetFloat64(123.45)
 				return c
 			},
-			expected: "z8nf4cjfzqod",
+			expected: "fz8nf4cjfzqod;",
 		},
 		{
 			name: "Time UID",
@@ -471,7 +475,7 @@ func TestCell_Uid(t *testing.T) {
 				c.SetTime(time.Unix(123456789, 0))
 				return c
 			},
-			expected: "21i3v9",
+			expected: "T21i3v9.0;",
 		},
 	}
 
@@ -485,6 +489,74 @@ func TestCell_Uid(t *testing.T) {
 	}
 }
 
+func TestCell_UidCollisions(t *testing.T) {
+	cell := func(data interface{}) *Cell {
+		return NewCellWithData("", data)
+	}
+	concat := func(cells ...*Cell) string {
+		uid := ""
+		for _, c := range cells {
+			uid += c.Uid()
+		}
+		return uid
+	}
+
+	testCases := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{
+			name:   "Concatenated strings",
+			first:  concat(cell("1"), cell("23")),
+			second: concat(cell("12"), cell("3")),
+		},
+		{
+			name:   "Concatenated integers",
+			first:  concat(cell(int64(1)), cell(int64(35))),
+			second: concat(cell(int64(36)), cell(int64(0))),
+		},
+		{
+			name:   "String equal to null key",
+			first:  cell("cnull").Uid(),
+			second: cell(nil).Uid(),
+		},
+		{
+			name:   "String equal to bool key",
+			first:  concat(cell("t")),
+			second: concat(cell(true)),
+		},
+		{
+			name:   "String containing a length prefix",
+			first:  concat(cell("a"), cell("s1:b")),
+			second: concat(cell("a"), cell("s1:"), cell("b")),
+		},
+		{
+			name:   "Integer and float with equal bits",
+			first:  cell(int64(math.Float64bits(1.5))).Uid(),
+			second: cell(1.5).Uid(),
+		},
+		{
+			name:   "Sub-second times",
+			first:  cell(time.Unix(123456789, 1)).Uid(),
+			second: cell(time.Unix(123456789, 2)).Uid(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.first == tc.second {
+				t.Errorf("expected identities %q and %q to differ", tc.first, tc.second)
+			}
+		})
+	}
+
+	utc := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	if cell(utc).Uid() != cell(utc.In(time.FixedZone("CET", 3600))).Uid() {
+		t.Errorf("expected equal instants in different locations to have equal identities")
+	}
+}
+
 func TestCell_Setters(t *testing.T) {
 	t.Run("SetBool", func(t *testing.T) {
 		c := NewCell("BOOL")
@@ -625,3 +697,337 @@ func TestCell_Remaining(t *testing.T) {
 		}
 	})
 }
+
+func TestCell_SetBytes(t *testing.T) {
+	src := []byte("hello")
+	c := NewCell("TEXT")
+	c.Scan(src)
+	// drivers may reuse the buffer once the row is scanned
+	copy(src, "world")
+	if s, _ := c.String(); s != "hello" {
+		t.Errorf("expected the cell to hold a copy of the scanned bytes, got %q", s)
+	}
+	if uid := c.Uid(); uid != "s5:hello" {
+		t.Errorf("expected UID %q, got %q", "s5:hello", uid)
+	}
+
+	// scanning again reuses the buffer, a string scanned afterwards replaces the bytes
+	c.Scan([]byte("12"))
+	if i, err := c.Int64(); err != nil || i != 12 {
+		t.Errorf("expected 12, got %d, err: %v", i, err)
+	}
+	c.Scan("text")
+	if s, _ := c.String(); s != "text" {
+		t.Errorf("expected %q, got %q", "text", s)
+	}
+}
+
+func TestCell_AppendUid(t *testing.T) {
+	cells := []*Cell{
+		NewCellWithData("", nil),
+		NewCellWithData("", true),
+		NewCellWithData("", int64(-42)),
+		NewCellWithData("", uint64(math.MaxUint64)),
+		NewCellWithData("", 1.5),
+		NewCellWithData("", "text"),
+		NewCellWithData("", []byte("bytes")),
+		NewCellWithData("", time.Unix(123456789, 5)),
+	}
+	prefix := []byte("prefix")
+	for _, c := range cells {
+		b := c.AppendUid(prefix[:len(prefix):len(prefix)])
+		if string(b) != "prefix"+c.Uid() {
+			t.Errorf("expected AppendUid to append %q, got %q", c.Uid(), b)
+		}
+	}
+}
+
+func TestCell_Raw(t *testing.T) {
+	now := time.Now()
+	for _, src := range []interface{}{int64(-42), uint64(math.MaxUint64), 1.5, true, "text", now} {
+		c := NewCellWithData("", src)
+		if raw := c.Raw(); raw != src {
+			t.Errorf("expected Raw to return %#v, got %#v", src, raw)
+		}
+	}
+
+	c := NewCellWithData("BYTEA", []byte{0, 1, 2})
+	if raw, ok := c.Raw().([]byte); !ok || string(raw) != "\x00\x01\x02" {
+		t.Errorf("expected Raw to return the scanned bytes, got %#v", c.Raw())
+	}
+	c.Scan(nil)
+	if raw := c.Raw(); raw != nil {
+		t.Errorf("expected Raw to return nil for NULL, got %#v", raw)
+	}
+	c.SetInt64(7)
+	if raw := c.Raw(); raw != int64(7) {
+		t.Errorf("expected Raw to return the value set, got %#v", raw)
+	}
+	if name := c.DatabaseTypeName(); name != "BYTEA" {
+		t.Errorf("expected database type name BYTEA, got %q", name)
+	}
+}
+
+func TestCell_RawReused(t *testing.T) {
+	// cells are reused for every row, a []byte row must not hide the source of the next rows
+	c := NewCell("")
+	for _, src := range []interface{}{[]byte("text"), int32(7), true, 1.5, uint64(3), time.Time{}} {
+		if err := c.Scan([]byte("bytes")); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if raw := c.Raw(); !reflect.DeepEqual(raw, src) {
+			t.Errorf("expected Raw to return %T %#v after a []byte row, got %T %#v", src, src, raw, raw)
+		}
+	}
+}
+
+func TestCell_Bytes(t *testing.T) {
+	c := NewCellWithData("", []byte("bytes"))
+	b, err := c.Bytes()
+	if err != nil || string(b) != "bytes" {
+		t.Fatalf("expected %q, got %q, err: %v", "bytes", b, err)
+	}
+	// Bytes returns a copy, which is kept when the cell is scanned again
+	c.Scan([]byte("other"))
+	if string(b) != "bytes" {
+		t.Errorf("expected a copy of the bytes, got %q", b)
+	}
+	c.Scan("text")
+	if b, err := c.Bytes(); err != nil || string(b) != "text" {
+		t.Errorf("expected %q, got %q, err: %v", "text", b, err)
+	}
+	c.Scan(int64(1))
+	if _, err := c.Bytes(); err == nil {
+		t.Error("expected an error converting an integer to []byte")
+	}
+}
+
+func TestCell_Uint64(t *testing.T) {
+	c := NewCellWithData("", uint64(math.MaxUint64))
+	if u, err := c.Uint64(); err != nil || u != math.MaxUint64 {
+		t.Errorf("expected %d, got %d, err: %v", uint64(math.MaxUint64), u, err)
+	}
+	if _, err := c.Int64(); err == nil {
+		t.Error("expected Int64 to overflow")
+	}
+	if _, err := c.Uint32(); err == nil {
+		t.Error("expected Uint32 to overflow")
+	}
+	if f, err := c.Float64(); err != nil || f != math.MaxUint64 {
+		t.Errorf("expected %v, got %v, err: %v", float64(math.MaxUint64), f, err)
+	}
+	if i, err := c.AsInterface(); err != nil || i != uint64(math.MaxUint64) {
+		t.Errorf("expected AsInterface to return the uint64, got %#v", i)
+	}
+
+	c.SetUint64(42)
+	if i, err := c.Int64(); err != nil || i != 42 {
+		t.Errorf("expected 42, got %d, err: %v", i, err)
+	}
+	c.SetInt64(-1)
+	if _, err := c.Uint64(); err == nil {
+		t.Error("expected a negative integer to overflow Uint64")
+	}
+	c.SetFloat64(12.5)
+	if u, err := c.Uint64(); err != nil || u != 12 {
+		t.Errorf("expected 12, got %d, err: %v", u, err)
+	}
+	c.SetString("18446744073709551615")
+	if u, err := c.Uint64(); err != nil || u != math.MaxUint64 {
+		t.Errorf("expected %d, got %d, err: %v", uint64(math.MaxUint64), u, err)
+	}
+}
+
+type scanLevel int8
+
+type scanValuer struct{ v driver.Value }
+
+func (v scanValuer) Value() (driver.Value, error) { return v.v, nil }
+
+// scanDecimal stands in for decimal types, which are scanned as their text
+type scanDecimal struct{ units, cents int }
+
+func (d scanDecimal) String() string { return fmt.Sprintf("%d.%02d", d.units, d.cents) }
+
+// scanBigDecimal implements fmt.Stringer with a pointer receiver
+type scanBigDecimal struct{ units int }
+
+func (d *scanBigDecimal) String() string { return fmt.Sprintf("%d.00", d.units) }
+
+func TestCell_ScanDriverValues(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	var nilInt *big.Int
+	var nilTime *time.Time
+	now := time.Now()
+	testCases := []struct {
+		name string
+		src  interface{}
+		kind reflect.Kind
+		text string      // value read with String for text, or with AsInterface formatted with %v
+		raw  interface{} // value returned by Raw, if it differs from src
+		null bool
+	}{
+		{name: "uint", src: uint(7), kind: reflect.Uint64, text: "7"},
+		{name: "uint8", src: uint8(255), kind: reflect.Uint64, text: "255"},
+		{name: "uint16", src: uint16(65535), kind: reflect.Uint64, text: "65535"},
+		{name: "uint32", src: uint32(math.MaxUint32), kind: reflect.Uint64, text: "4294967295"},
+		{name: "uint64", src: uint64(math.MaxUint64), kind: reflect.Uint64, text: "18446744073709551615"},
+		{name: "float32", src: float32(1.5), kind: reflect.Float64, text: "1.5"},
+		{name: "json.RawMessage", src: json.RawMessage(`{"a":1}`), kind: reflect.String, text: `{"a":1}`},
+		{name: "driver.Valuer", src: scanValuer{int64(42)}, kind: reflect.Int64, text: "42", raw: int64(42)},
+		{name: "nested driver.Valuer", src: scanValuer{scanValuer{"text"}}, kind: reflect.String, text: "text", raw: "text"},
+		{name: "driver.Valuer of bytes", src: scanValuer{[]byte("bytes")}, kind: reflect.String, text: "bytes", raw: []byte("bytes")},
+		{name: "driver.Valuer of NULL", src: scanValuer{nil}, null: true},
+		{name: "big.Int", src: big.NewInt(-5), kind: reflect.Int64, text: "-5"},
+		{name: "unsigned big.Int", src: new(big.Int).SetUint64(math.MaxUint64), kind: reflect.Uint64, text: "18446744073709551615"},
+		{name: "huge big.Int", src: huge, kind: reflect.String, text: "123456789012345678901234567890"},
+		{name: "nil big.Int", src: nilInt, null: true},
+		{name: "Stringer", src: scanDecimal{12, 5}, kind: reflect.String, text: "12.05"},
+		{name: "pointer Stringer", src: &scanBigDecimal{7}, kind: reflect.String, text: "7.00"},
+		{name: "named int", src: scanLevel(-3), kind: reflect.Int64, text: "-3"},
+		{name: "pointer", src: &now, kind: reflect.Struct, text: fmt.Sprint(now)},
+		{name: "nil pointer", src: nilTime, null: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCellWithData("", "previous value")
+			if err := c.Scan(tc.src); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.IsNull() != tc.null {
+				t.Fatalf("expected null %v, got %v", tc.null, c.IsNull())
+			}
+			if tc.null {
+				return
+			}
+			if c.Kind() != tc.kind {
+				t.Errorf("expected kind %v, got %v", tc.kind, c.Kind())
+			}
+			i, err := c.AsInterface()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(i); got != tc.text {
+				t.Errorf("expected %q, got %q", tc.text, got)
+			}
+			if tc.raw != nil {
+				if raw := c.Raw(); !reflect.DeepEqual(raw, tc.raw) {
+					t.Errorf("expected Raw to return %#v, got %#v", tc.raw, raw)
+				}
+			} else if raw := c.Raw(); tc.kind != reflect.String && !reflect.DeepEqual(raw, tc.src) {
+				t.Errorf("expected Raw to return %#v, got %#v", tc.src, raw)
+			}
+		})
+	}
+}
+
+func TestCell_ScanUnsupported(t *testing.T) {
+	for _, src := range []interface{}{map[string]int{}, []int{1}, struct{}{}, scanValuer{[]string{}}} {
+		c := NewCellWithData("", int64(1))
+		err := c.Scan(src)
+		if err == nil {
+			t.Errorf("expected an error scanning %T", src)
+		}
+		if !c.IsNull() {
+			t.Errorf("expected the cell to be NULL after failing to scan %T", src)
+		}
+	}
+}
+
+func TestCell_Float32(t *testing.T) {
+	for _, src := range []interface{}{float32(1.5), 1.5, int64(2), uint64(3)} {
+		c := NewCellWithData("", src)
+		f, err := c.Float32()
+		if err != nil || float64(f) != reflect.ValueOf(src).Convert(reflect.TypeOf(float64(0))).Float() {
+			t.Errorf("Float32 of %T %v: got %v, err: %v", src, src, f, err)
+		}
+	}
+}
+
+func TestCell_BigNumbers(t *testing.T) {
+	digits := "123456789012345678901234567890.123456789"
+	c := NewCellWithData("", []byte(digits))
+
+	r, err := c.BigRat()
+	if expected, _ := new(big.Rat).SetString(digits); err != nil || r.Cmp(expected) != 0 {
+		t.Errorf("BigRat: expected %v, got %v, err: %v", expected, r, err)
+	}
+	f, err := c.BigFloat()
+	if err != nil || f.Text('f', 9) != digits {
+		t.Errorf("BigFloat: expected %s, got %v, err: %v", digits, f, err)
+	}
+	if _, err := c.BigInt(); err == nil {
+		t.Error("BigInt: expected an error for a fractional value")
+	}
+
+	c.Scan("123456789012345678901234567890.000")
+	if i, err := c.BigInt(); err != nil || i.String() != "123456789012345678901234567890" {
+		t.Errorf("BigInt: expected 123456789012345678901234567890, got %v, err: %v", i, err)
+	}
+
+	for _, src := range []interface{}{int64(-5), uint64(math.MaxUint64), 2.0} {
+		c := NewCellWithData("", src)
+		expected := fmt.Sprint(src)
+		if i, err := c.BigInt(); err != nil || i.String() != expected {
+			t.Errorf("BigInt of %T: expected %s, got %v, err: %v", src, expected, i, err)
+		}
+		if r, err := c.BigRat(); err != nil || r.RatString() != expected {
+			t.Errorf("BigRat of %T: expected %s, got %v, err: %v", src, expected, r, err)
+		}
+		if f, err := c.BigFloat(); err != nil || f.Text('f', 0) != expected {
+			t.Errorf("BigFloat of %T: expected %s, got %v, err: %v", src, expected, f, err)
+		}
+	}
+
+	for _, src := range []interface{}{"abc", 1.5, math.NaN(), true} {
+		c := NewCellWithData("", src)
+		if _, err := c.BigInt(); err == nil {
+			t.Errorf("BigInt of %v: expected an error", src)
+		}
+	}
+	for _, src := range []interface{}{"abc", math.Inf(1), true} {
+		c := NewCellWithData("", src)
+		if _, err := c.BigRat(); err == nil {
+			t.Errorf("BigRat of %v: expected an error", src)
+		}
+	}
+	for _, src := range []interface{}{"abc", math.NaN(), true} {
+		c := NewCellWithData("", src)
+		if _, err := c.BigFloat(); err == nil {
+			t.Errorf("BigFloat of %v: expected an error", src)
+		}
+	}
+}
+
+func TestCell_UUID(t *testing.T) {
+	expected := [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	testCases := []struct {
+		name    string
+		colType string
+		src     interface{}
+	}{
+		{"text", "UUID", "12345678-9abc-def0-0123-456789abcdef"},
+		{"upper case text", "", []byte("12345678-9ABC-DEF0-0123-456789ABCDEF")},
+		{"hex", "CHAR", "123456789abcdef00123456789abcdef"},
+		{"binary", "BINARY", expected[:]},
+		{"uniqueidentifier", "UNIQUEIDENTIFIER", []byte{0x78, 0x56, 0x34, 0x12, 0xbc, 0x9a, 0xf0, 0xde, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}},
+		{"uniqueidentifier text", "UNIQUEIDENTIFIER", "12345678-9ABC-DEF0-0123-456789ABCDEF"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := NewCellWithData(tc.colType, tc.src).UUID()
+			if err != nil || u != expected {
+				t.Errorf("expected %x, got %x, err: %v", expected, u, err)
+			}
+		})
+	}
+
+	for _, src := range []interface{}{"12345678-9abc-def0-0123-456789abcdeg", "12345678_9abc_def0_0123_456789abcdef", "1234", int64(1), nil} {
+		if _, err := NewCellWithData("", src).UUID(); err == nil {
+			t.Errorf("UUID of %v: expected an error", src)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package value
 
 import (
 	"database/sql"
+	"math/big"
 	"reflect"
 	"time"
 
@@ -37,6 +38,9 @@ const (
 	Uint64
 	Bool
 	String //  note, []uint8get converted to string, this is because mysql returns []uint8 for varchar while pg returns string
+	BigInt
+	BigRat
+	BigFloat
 )
 
 var BasicKinds = map[reflect.Kind]Value{
@@ -65,6 +69,17 @@ var BasicTypes = map[reflect.Type]Value{
 	reflect.TypeOf(sql.NullInt64{}):       NullInt64,
 	reflect.TypeOf(sql.NullString{}):      NullString,
 	reflect.TypeOf(sql.NullTime{}):        NullTime,
+	reflect.TypeOf(big.Int{}):             BigInt,
+	reflect.TypeOf(big.Rat{}):             BigRat,
+	reflect.TypeOf(big.Float{}):           BigFloat,
+}
+
+// UniqueIdentifier is the database type name of SQL Server UUID columns, whose binary form is mixed-endian
+const UniqueIdentifier = "UNIQUEIDENTIFIER"
+
+// IsUUIDType reports whether t is a UUID, ie a type whose underlying type is [16]byte such as github.com/google/uuid.UUID
+func IsUUIDType(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8
 }
 
 var NullableTypes = map[reflect.Type]Value{